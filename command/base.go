@@ -2,9 +2,13 @@ package command
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
 
 	"github.com/hmerritt/reactenv/ui"
 
@@ -13,24 +17,43 @@ import (
 )
 
 // Slice of all flag names
-var FlagNames = []string{flagStrict.Name, flagForce.Name}
+var FlagNames = []string{flagStrict.Name, flagForce.Name, flagMatch.Name, flagInclude.Name, flagExclude.Name, flagMaxDepth.Name, flagEnvFile.Name, flagJSONFile.Name, flagDryRun.Name, flagTemplates.Name, flagTemplateDir.Name, flagConcurrency.Name, flagStreamThreshold.Name, flagOut.Name, flagCompressed.Name, flagCSPHeaderFile.Name, flagGroupBy.Name, flagFormat.Name, flagOutput.Name, flagReporter.Name, flagNoColor.Name, flagASCII.Name}
 
 // Slice of global flag names
-var FlagNamesGlobal = []string{flagStrict.Name, flagForce.Name}
+var FlagNamesGlobal = []string{flagStrict.Name, flagForce.Name, flagOutput.Name, flagNoColor.Name, flagASCII.Name}
+
+// Slice of flag names used when finding files
+var FlagNamesFind = []string{flagMatch.Name, flagInclude.Name, flagExclude.Name, flagMaxDepth.Name}
+
+// Slice of flag names used for templates
+var FlagNamesTemplate = []string{flagTemplates.Name, flagTemplateDir.Name}
+
+// Slice of flag names used when resolving environment variable values
+var FlagNamesEnv = []string{flagEnvFile.Name, flagJSONFile.Name}
 
 // Master command type which is present in all commands
 //
 // Used to standardize UI output
 type BaseCommand struct {
 	UI *ui.Ui
+	// Machine-readable report, when `--output json` is used (nil otherwise)
+	Report *Report
+	// Exit code when the command fails with an error (rather than e.g. missing values)
+	ErrorCode int
 }
 
 func GetBaseCommand() *BaseCommand {
 	return &BaseCommand{
-		UI: ui.GetUi(),
+		UI:        ui.GetUi(),
+		ErrorCode: 1,
 	}
 }
 
+// Returns a context that is cancelled on SIGINT/SIGTERM (any changes being written are rolled back)
+func (c *BaseCommand) SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
 type Flag struct {
 	Name       string
 	Usage      string
@@ -53,28 +76,81 @@ func (fm *FlagMap) Get(flagName string) *Flag {
 func (fm *FlagMap) Help() string {
 	var out bytes.Buffer
 
-	for _, flag := range *fm {
-		fmt.Fprintf(&out, "  --%s \n      %s\n\n", flag.Name, flag.Usage)
+	// Sort flag names, map order is random
+	names := make([]string, 0, len(*fm))
+	for name := range *fm {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(&out, "  --%s \n      %s\n\n", name, (*fm)[name].Usage)
 	}
 
 	return strings.TrimRight(out.String(), "\n")
 }
 
-// Parse CLI args to FlagMap
+// Parse CLI args to FlagMap, exits if any flag can not be parsed
 func (fm *FlagMap) Parse(UI *ui.Ui, args []string) []string {
+	args, err := fm.ParseArgs(UI, args)
+
+	if err != nil {
+		exit(1)
+	}
+
+	return args
+}
+
+// Parse CLI args to FlagMap, returns an error (after outputting it) if any flag can not be parsed
+func (fm *FlagMap) ParseArgs(UI *ui.Ui, args []string) ([]string, error) {
 	// Struct used to parse flags
 	var opts struct {
-		Strict bool `short:"s" long:"strict"`
-		Force  bool `short:"f" long:"force"`
+		Strict   bool     `short:"s" long:"strict"`
+		Force    bool     `short:"f" long:"force"`
+		Match    []string `short:"m" long:"match"`
+		Include  []string `long:"include"`
+		Exclude  []string `long:"exclude"`
+		MaxDepth int      `long:"max-depth"`
+		EnvFile  []string `short:"e" long:"env-file"`
+		JSONFile []string `long:"json-file"`
+		DryRun   bool     `long:"dry-run"`
+		Out      string   `long:"out"`
+
+		Compressed    bool   `long:"compressed"`
+		CSPHeaderFile string `long:"csp-header-file"`
+
+		GroupBy string `long:"group-by"`
+		Format  string `long:"format"`
+		Output  string `long:"output"`
+
+		Reporter []string `long:"reporter"`
+
+		NoColor bool `long:"no-color"`
+		ASCII   bool `long:"ascii"`
+
+		Templates   bool   `long:"templates"`
+		TemplateDir string `long:"template-dir"`
+
+		Concurrency     int `long:"concurrency"`
+		StreamThreshold int `long:"stream-threshold"`
 	}
 
 	// Parse flags from `args'.
-	args, err := flags.ParseArgs(&opts, flagSingleToDoubleDash(args))
+	parser := flags.NewParser(&opts, flags.Default)
+	args, err := parser.ParseArgs(flagSingleToDoubleDash(args))
 
 	if err != nil {
 		UI.Error("Unable to parse flag from the arguments entered '" + fmt.Sprint(args[0]) + "'")
 		UI.Warn("Flags are entered with double dashes '--', for example '--strict'")
-		os.Exit(1)
+		return nil, err
+	}
+
+	// Every flag is parsed, so reject any that this command does not use (rather than ignoring them)
+	for _, name := range FlagNames {
+		if option := parser.FindOptionByLongName(name); option != nil && option.IsSet() && fm.Get(name) == nil {
+			UI.Error(fmt.Sprintf("Flag '--%s' can not be used with this command.", name))
+			return nil, fmt.Errorf("unknown flag '--%s'", name)
+		}
 	}
 
 	updateFmWithOps := func(flagName string, value interface{}) {
@@ -89,8 +165,28 @@ func (fm *FlagMap) Parse(UI *ui.Ui, args []string) []string {
 
 	updateFmWithOps("strict", opts.Strict)
 	updateFmWithOps("force", opts.Force)
+	updateFmWithOps("match", opts.Match)
+	updateFmWithOps("include", opts.Include)
+	updateFmWithOps("exclude", opts.Exclude)
+	updateFmWithOps("max-depth", opts.MaxDepth)
+	updateFmWithOps("env-file", opts.EnvFile)
+	updateFmWithOps("json-file", opts.JSONFile)
+	updateFmWithOps("dry-run", opts.DryRun)
+	updateFmWithOps("out", opts.Out)
+	updateFmWithOps("compressed", opts.Compressed)
+	updateFmWithOps("csp-header-file", opts.CSPHeaderFile)
+	updateFmWithOps("group-by", opts.GroupBy)
+	updateFmWithOps("format", opts.Format)
+	updateFmWithOps("output", opts.Output)
+	updateFmWithOps("reporter", opts.Reporter)
+	updateFmWithOps("no-color", opts.NoColor)
+	updateFmWithOps("ascii", opts.ASCII)
+	updateFmWithOps("templates", opts.Templates)
+	updateFmWithOps("template-dir", opts.TemplateDir)
+	updateFmWithOps("concurrency", opts.Concurrency)
+	updateFmWithOps("stream-threshold", opts.StreamThreshold)
 
-	return args
+	return args, nil
 }
 
 // flag definitions
@@ -114,3 +210,203 @@ var flagForce = Flag{
 	Default: false,
 	Value:   false,
 }
+
+// flag --match
+//
+// File name matchers (regex or extension list)
+var flagMatch = Flag{
+	Name:    "match",
+	Usage:   "Only scan files with a name matching this regex, or extension list (e.g. '.js,.mjs,.cjs'). Can be repeated. Defaults to '.js' files.",
+	Default: []string{},
+	Value:   []string{},
+}
+
+// flag --include
+//
+// Glob patterns a file must match to be scanned
+var flagInclude = Flag{
+	Name:    "include",
+	Usage:   "Only scan files matching this glob pattern (relative to PATH). Can be repeated.",
+	Default: []string{},
+	Value:   []string{},
+}
+
+// flag --exclude
+//
+// Glob patterns for files and directories to skip
+var flagExclude = Flag{
+	Name:    "exclude",
+	Usage:   "Skip files and directories matching this glob pattern (relative to PATH). Can be repeated.",
+	Default: []string{},
+	Value:   []string{},
+}
+
+// flag --max-depth
+//
+// Limit how deep directories are scanned
+var flagMaxDepth = Flag{
+	Name:    "max-depth",
+	Usage:   "Maximum directory depth to scan, where 1 only scans PATH itself (0 is unlimited).",
+	Default: 0,
+	Value:   0,
+}
+
+// flag --env-file
+//
+// Load environment variable values from `.env` files
+var flagEnvFile = Flag{
+	Name:    "env-file",
+	Usage:   "Load environment variables from a .env file. Can be repeated, later files take precedence. Host environment variables always take precedence over .env files.",
+	Default: []string{},
+	Value:   []string{},
+}
+
+// flag --json-file
+//
+// Load environment variable values from JSON files
+var flagJSONFile = Flag{
+	Name:    "json-file",
+	Usage:   "Load environment variables from a JSON file (a single object of keys and values). Can be repeated, later files take precedence. Host environment variables and .env files take precedence over JSON files.",
+	Default: []string{},
+	Value:   []string{},
+}
+
+// flag --dry-run
+//
+// Preview changes without writing any files
+var flagDryRun = Flag{
+	Name:    "dry-run",
+	Usage:   "Show a diff of every change, without writing any files. Exits with an error if any values are missing.",
+	Default: false,
+	Value:   false,
+}
+
+// flag --out
+//
+// Write injected files to a separate directory
+var flagOut = Flag{
+	Name:    "out",
+	Usage:   "Write a copy of PATH (including files without placeholders) to this directory with all environment variables injected, leaving PATH untouched. Unchanged files are hard-linked where possible.",
+	Default: "",
+	Value:   "",
+}
+
+// flag --templates
+//
+// Keep templates of injected files
+var flagTemplates = Flag{
+	Name:    "templates",
+	Usage:   "Keep the templates (original contents, with placeholders) of injected files, so they can be injected again with new values, or restored with 'reactenv restore'. Templates are kept in '.reactenv' within PATH, unless '--template-dir' is used.",
+	Default: false,
+	Value:   false,
+}
+
+// flag --template-dir
+//
+// Directory where templates are kept
+var flagTemplateDir = Flag{
+	Name:    "template-dir",
+	Usage:   "Directory where the templates of injected files are kept, e.g. outside of your web root (implies '--templates', defaults to '.reactenv' within PATH).",
+	Default: "",
+	Value:   "",
+}
+
+// flag --concurrency
+//
+// Number of files processed at once
+var flagConcurrency = Flag{
+	Name:    "concurrency",
+	Usage:   "Number of files processed at once (defaults to the number of CPUs).",
+	Default: 0,
+	Value:   0,
+}
+
+// flag --stream-threshold
+//
+// Files larger than this (in MB) are streamed
+var flagStreamThreshold = Flag{
+	Name:    "stream-threshold",
+	Usage:   "Files larger than this (in MB) are streamed, rather than read into memory (defaults to 16). Use -1 to never stream.",
+	Default: 0,
+	Value:   0,
+}
+
+// flag --compressed
+//
+// Inject into precompressed assets with no uncompressed file
+var flagCompressed = Flag{
+	Name:    "compressed",
+	Usage:   "Also inject into precompressed assets (.gz, .br) that have no uncompressed file next to them.",
+	Default: false,
+	Value:   false,
+}
+
+// flag --csp-header-file
+//
+// Write the CSP hashes of inline scripts and styles to a file
+var flagCSPHeaderFile = Flag{
+	Name:    "csp-header-file",
+	Usage:   "Write the sha256 hashes of every inline script and style (after injection) to this file, as Content-Security-Policy directives (e.g. for an nginx header).",
+	Default: "",
+	Value:   "",
+}
+
+// flag --group-by
+//
+// Group listed environment variables by key or by file
+var flagGroupBy = Flag{
+	Name:    "group-by",
+	Usage:   "Group environment variables by 'key' (each file using a key), or by 'file' (each key used in a file). Defaults to 'key'.",
+	Default: "",
+	Value:   "",
+}
+
+// flag --format
+//
+// Format of listed environment variables
+var flagFormat = Flag{
+	Name:    "format",
+	Usage:   "Output format, one of 'table', 'json' or 'csv'. Defaults to 'table' (must be 'table' with '--output json').",
+	Default: "",
+	Value:   "",
+}
+
+// flag --output
+//
+// Output a machine-readable report of the command
+var flagOutput = Flag{
+	Name:    "output",
+	Usage:   "Output format of the command, either 'text' or 'json'. With 'json', a single JSON report is written to stdout (with the status, files, occurrences, keys and durations, but never values), and all other output is written to stderr. Defaults to 'text'.",
+	Default: "",
+	Value:   "",
+}
+
+// flag --reporter
+//
+// Report missing and invalid values in a CI format
+var flagReporter = Flag{
+	Name:    "reporter",
+	Usage:   "Report missing and invalid values (and keys that look like secrets) in a CI format: 'github' (Actions annotations), 'junit' or 'sarif'. Add '=FILE' to write the report to a file (e.g. 'junit=report.xml'), otherwise it is output with everything else. Can be repeated.",
+	Default: []string{},
+	Value:   []string{},
+}
+
+// flag --no-color
+//
+// Disable colored output
+var flagNoColor = Flag{
+	Name:    "no-color",
+	Usage:   "Disable colored output. Colors are already disabled when output is not a terminal, or when the 'NO_COLOR' environment variable is set ('FORCE_COLOR' enables them).",
+	Default: false,
+	Value:   false,
+}
+
+// flag --ascii
+//
+// Only output ASCII characters
+var flagASCII = Flag{
+	Name:    "ascii",
+	Usage:   "Only output ASCII characters, using '[x]', '[ ]' and '[-]' in checklists, and a plain spinner. Enabled by default for dumb terminals and legacy Windows consoles.",
+	Default: false,
+	Value:   false,
+}
@@ -1,7 +1,16 @@
 package command
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hmerritt/reactenv/reactenv"
+	"github.com/hmerritt/reactenv/reporter"
+	"github.com/hmerritt/reactenv/ui"
 )
 
 // Populate map of select flags (defaults to ALL flags)
@@ -33,10 +42,308 @@ func GetFlagMap(which []string) *FlagMap {
 
 	addToMap(&flagStrict)
 	addToMap(&flagForce)
+	addToMap(&flagMatch)
+	addToMap(&flagInclude)
+	addToMap(&flagExclude)
+	addToMap(&flagMaxDepth)
+	addToMap(&flagEnvFile)
+	addToMap(&flagJSONFile)
+	addToMap(&flagDryRun)
+	addToMap(&flagOut)
+	addToMap(&flagTemplates)
+	addToMap(&flagTemplateDir)
+	addToMap(&flagConcurrency)
+	addToMap(&flagStreamThreshold)
+	addToMap(&flagCompressed)
+	addToMap(&flagCSPHeaderFile)
+	addToMap(&flagGroupBy)
+	addToMap(&flagFormat)
+	addToMap(&flagOutput)
+	addToMap(&flagNoColor)
+	addToMap(&flagASCII)
+	addToMap(&flagReporter)
 
 	return &fm
 }
 
+// Join multiple slices of flag names into one
+func JoinFlagNames(names ...[]string) []string {
+	joined := make([]string, 0)
+	for _, n := range names {
+		joined = append(joined, n...)
+	}
+	return joined
+}
+
+// Builds the source of environment variable values from `FlagNamesEnv` flags.
+//
+// Host environment variables take precedence over `--env-file` files, which
+// take precedence over `--json-file` files (see `reactenv.NewValueSource`).
+func ValueSourceFromFlags(flags *FlagMap) (reactenv.ValueSource, error) {
+	return reactenv.NewValueSource(flags.Get(flagEnvFile.Name).Value.([]string), flags.Get(flagJSONFile.Name).Value.([]string))
+}
+
+// Slice of flag names used when scanning files (shared by commands that read occurrences)
+var FlagNamesScan = JoinFlagNames(FlagNamesFind, FlagNamesEnv, FlagNamesTemplate, []string{flagConcurrency.Name, flagStreamThreshold.Name, flagCompressed.Name})
+
+// Creates a Reactenv from `FlagNamesScan` flags
+func NewReactenvFromFlags(flags *FlagMap) (*reactenv.Reactenv, error) {
+	renv := reactenv.NewReactenv()
+	renv.MaxDepth = flags.Get(flagMaxDepth.Name).Value.(int)
+	renv.Include = flags.Get(flagInclude.Name).Value.([]string)
+	renv.Exclude = flags.Get(flagExclude.Name).Value.([]string)
+	renv.TemplateDir = flags.Get(flagTemplateDir.Name).Value.(string)
+	renv.Templates = flags.Get(flagTemplates.Name).Value.(bool) || renv.TemplateDir != ""
+	renv.Compressed = flags.Get(flagCompressed.Name).Value.(bool)
+	renv.Concurrency = flags.Get(flagConcurrency.Name).Value.(int)
+	renv.StreamThreshold = int64(flags.Get(flagStreamThreshold.Name).Value.(int)) << 20
+
+	values, err := ValueSourceFromFlags(flags)
+
+	if err != nil {
+		return nil, err
+	}
+
+	renv.Values = values
+
+	// Only used by `reactenv run`, but set before files are found (so `--out` is never scanned)
+	if out := flags.Get(flagOut.Name); out != nil {
+		renv.OutDir = out.Value.(string)
+	}
+
+	if cspHeaderFile := flags.Get(flagCSPHeaderFile.Name); cspHeaderFile != nil {
+		renv.CSPHeaderFile = cspHeaderFile.Value.(string)
+	}
+
+	return renv, nil
+}
+
+// Finds every file matching `--match` within `pathToAssets`, and every occurrence
+// within them (adding both to the report). Exits with `c.ErrorCode` if anything
+// fails. If no files are found, `renv` is returned without finding occurrences.
+func (c *BaseCommand) scan(ctx context.Context, flags *FlagMap, pathToAssets string) *reactenv.Reactenv {
+	renv, err := NewReactenvFromFlags(flags)
+
+	if err != nil {
+		c.UI.Error("Error loading environment variables file.\n")
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.Exit(ReportStatusError, c.ErrorCode)
+	}
+
+	step := ui.InitDuration(c.UI)
+	err = renv.FindFiles(ctx, pathToAssets, flags.Get(flagMatch.Name).Value.([]string))
+	c.Report.Time("find_files", step)
+
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error reading files in PATH '%s'.\n", pathToAssets))
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.Exit(ReportStatusError, c.ErrorCode)
+	}
+
+	if len(renv.Files) == 0 {
+		return renv
+	}
+
+	step = ui.InitDuration(c.UI)
+	err = renv.FindOccurrences(ctx)
+	c.Report.Time("find_occurrences", step)
+
+	if err != nil {
+		c.UI.Error("Error when finding environment variables.\n")
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.Exit(ReportStatusError, c.ErrorCode)
+	}
+
+	c.Report.SetOccurrences(renv)
+
+	return renv
+}
+
+// Returns the warning output when no files within `pathToAssets` match `--match`
+func noFilesFound(renv *reactenv.Reactenv, pathToAssets string) string {
+	return fmt.Sprintf("No files found in path '%s' using %s '%s'", pathToAssets, ui.Pluralize("matcher", len(renv.FileMatchers)), strings.Join(renv.FileMatchExpressions(), "', '"))
+}
+
+// Outputs the number of occurrences in each file
+func (c *BaseCommand) OutputOccurrences(renv *reactenv.Reactenv) {
+	c.UI.Output(
+		fmt.Sprintf(
+			"Found %d reactenv environment %s in %d/%d matching files (%s '%s'):",
+			renv.OccurrencesTotal,
+			ui.Pluralize("variable", renv.OccurrencesTotal),
+			len(renv.Files),
+			renv.FilesMatchTotal,
+			ui.Pluralize("matcher", len(renv.FileMatchers)),
+			strings.Join(renv.FileMatchExpressions(), "', '"),
+		),
+	)
+	for fileIndex, fileOccurrencesTotal := range renv.OccurrencesByFile {
+		c.UI.Output(
+			fmt.Sprintf(
+				"  - %4dx in %s",
+				len(fileOccurrencesTotal.Occurrences),
+				renv.Files[fileIndex].Path,
+			),
+		)
+	}
+	c.UI.Output("")
+}
+
+// Outputs a checklist of every environment variable (ticked if set, or using
+// a default value). Returns the number of required values that are not set.
+func (c *BaseCommand) OutputChecklist(renv *reactenv.Reactenv) int {
+	envKeysSet := make([]string, 0)
+	envKeysDefault := make([]string, 0)
+	envKeysOptional := make([]string, 0)
+	for _, occurrenceKey := range renv.OccurrenceKeysSorted() {
+		_, isSet := renv.OccurrenceKeysReplacement[occurrenceKey]
+		switch {
+		case isSet || renv.OccurrenceKeysRequired[occurrenceKey]:
+			envKeysSet = append(envKeysSet, occurrenceKey)
+		case renv.OccurrenceKeysDefault[occurrenceKey]:
+			envKeysDefault = append(envKeysDefault, occurrenceKey)
+		default:
+			envKeysOptional = append(envKeysOptional, occurrenceKey)
+		}
+	}
+
+	glyphs := ui.GetGlyphs()
+	envValuesMissing := 0
+	if len(envKeysSet) > 0 {
+		c.UI.Output(fmt.Sprintf("Environment %s checklist (ticked if value has been set):", ui.Pluralize("variable", len(envKeysSet))))
+		for _, occurrenceKey := range envKeysSet {
+			check := glyphs.Tick
+			if _, ok := renv.OccurrenceKeysReplacement[occurrenceKey]; !ok {
+				check = glyphs.Cross
+				envValuesMissing++
+			}
+			c.UI.Output(fmt.Sprintf("  - %4s %s", check, occurrenceKey))
+		}
+		c.UI.Output("")
+	}
+
+	if len(envKeysDefault) > 0 {
+		c.UI.Output(fmt.Sprintf("Environment %s not set, using default value:", ui.Pluralize("variable", len(envKeysDefault))))
+		for _, occurrenceKey := range envKeysDefault {
+			c.UI.Output(fmt.Sprintf("  - %4s %s", glyphs.Dash, occurrenceKey))
+		}
+		c.UI.Output("")
+	}
+
+	if len(envKeysOptional) > 0 {
+		c.UI.Output(fmt.Sprintf("Optional environment %s not set, left empty:", ui.Pluralize("variable", len(envKeysOptional))))
+		for _, occurrenceKey := range envKeysOptional {
+			c.UI.Output(fmt.Sprintf("  - %4s %s", glyphs.Dash, occurrenceKey))
+		}
+		c.UI.Output("")
+	}
+
+	return envValuesMissing
+}
+
+// Outputs every occurrence that can not be injected (e.g. a value that is not valid for its type)
+func (c *BaseCommand) OutputOccurrenceErrors(renv *reactenv.Reactenv) {
+	c.UI.Error(fmt.Sprintf("Unable to inject %d environment %s:", len(renv.OccurrenceErrors), ui.Pluralize("variable", len(renv.OccurrenceErrors))))
+	for _, occurrenceError := range renv.OccurrenceErrors {
+		c.UI.Error(ui.WrapAtLength(fmt.Sprintf("  - %s in %s (at byte %d): %v", occurrenceError.Occurrence.Key, occurrenceError.File.Path, occurrenceError.Occurrence.StartEnd[0], occurrenceError.Err), 6))
+	}
+}
+
+// Reporter to write, from `--reporter NAME[=FILE]`
+type ReporterOutput struct {
+	Name   string
+	Report reporter.Reporter
+	// File to write the report to (output with everything else, if empty)
+	File string
+}
+
+// Returns every reporter in `--reporter` flags
+func ReportersFromFlags(flags *FlagMap) ([]ReporterOutput, error) {
+	reporters := make([]ReporterOutput, 0)
+	for _, value := range flags.Get(flagReporter.Name).Value.([]string) {
+		name, file, _ := strings.Cut(value, "=")
+		report, ok := reporter.Reporters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown reporter '%s', must be one of 'github', 'junit', 'sarif'", name)
+		}
+		reporters = append(reporters, ReporterOutput{Name: name, Report: report, File: file})
+	}
+	return reporters, nil
+}
+
+// Writes every reporter, with the keys and findings of `renv`
+func (c *BaseCommand) WriteReporters(reporters []ReporterOutput, renv *reactenv.Reactenv, command string, duration time.Duration) error {
+	if len(reporters) == 0 {
+		return nil
+	}
+
+	result, err := reporter.FromReactenv(renv, command, duration)
+
+	if err != nil {
+		return err
+	}
+
+	for _, output := range reporters {
+		var report bytes.Buffer
+
+		if err := output.Report(&report, result); err != nil {
+			return fmt.Errorf("unable to write '%s' report: %w", output.Name, err)
+		}
+
+		if output.File == "" {
+			if report.Len() > 0 {
+				c.UI.Output(strings.TrimSuffix(report.String(), "\n"))
+			}
+			continue
+		}
+
+		if err := os.WriteFile(output.File, report.Bytes(), 0644); err != nil {
+			return fmt.Errorf("unable to write '%s' report: %w", output.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Reports whether `args` request machine-readable output (e.g. '--format json',
+// or '--output json'), in which case nothing other than that output should be
+// written to stdout.
+//
+// Checked before flags are parsed, as the title is printed before any command runs.
+func IsMachineOutput(args []string) bool {
+	format := flagValueFromArgs(args, flagFormat.Name)
+	return (format != "" && format != "table") || flagValueFromArgs(args, flagOutput.Name) == "json"
+}
+
+// Returns the (last) value of a string flag in `args`, before flags are parsed
+func flagValueFromArgs(args []string, flagName string) string {
+	flagValue := ""
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != flagName {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		flagValue = value
+	}
+	return flagValue
+}
+
+// Reports whether a bool flag is set in `args`, before flags are parsed
+func flagSetInArgs(args []string, flagName string) bool {
+	isSet := false
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != flagName {
+			continue
+		}
+		isSet = !hasValue || value == "true"
+	}
+	return isSet
+}
+
 // Detect long flags entered with one dash '-'
 // and add a dash to prevent a panic when parsing
 //
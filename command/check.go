@@ -0,0 +1,142 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hmerritt/reactenv/reactenv"
+	"github.com/hmerritt/reactenv/ui"
+)
+
+// Exit codes of `reactenv check`
+const (
+	checkExitOk             = 0 // All environment variables are set
+	checkExitMissing        = 1 // Environment variables are missing or invalid
+	checkExitNoPlaceholders = 2 // No matching files, or no environment variables in them
+	checkExitError          = 3 // An error occurred (e.g. invalid flags, or a file could not be read)
+)
+
+type CheckCommand struct {
+	*BaseCommand
+}
+
+func (c *CheckCommand) Synopsis() string {
+	return "Check every environment variable is set, without writing any files"
+}
+
+func (c *CheckCommand) Help() string {
+	helpText := fmt.Sprintf(`
+Usage: reactenv check [options] PATH
+
+Check every environment variable used in a built react app has been set,
+without writing any files. Useful in CI, and in container healthchecks.
+
+Example:
+  $ reactenv check ./dist/assets
+
+Exit codes:
+  0  All environment variables are set
+  1  Environment variables are missing (or have invalid values)
+  2  No matching files, or no environment variables were found in them
+  3  An error occurred (e.g. invalid flags, or a file could not be read)
+
+Options:
+%s
+`, c.Flags().Help())
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *CheckCommand) Flags() *FlagMap {
+	return GetFlagMap(JoinFlagNames(FlagNamesGlobal, FlagNamesScan, []string{flagReporter.Name}))
+}
+
+func (c *CheckCommand) Run(args []string) int {
+	duration := ui.InitDuration(c.UI)
+
+	ctx, stop := c.SignalContext()
+	defer stop()
+
+	c.ErrorCode = checkExitError
+	c.InitReport("check", args)
+
+	flags := c.Flags()
+	args, err := flags.ParseArgs(c.UI, args)
+
+	if err != nil {
+		c.Exit(ReportStatusError, checkExitError)
+	}
+
+	if len(args) == 0 {
+		c.UI.Error("No asset PATH entered.")
+		c.exitWithHelp()
+	}
+
+	pathToAssets := args[0]
+
+	if _, err := os.Stat(pathToAssets); os.IsNotExist(err) {
+		c.UI.Error(fmt.Sprintf("File PATH '%s' does not exist.", pathToAssets))
+		c.exitWithHelp()
+	}
+
+	fileMatchExpressions := flags.Get(flagMatch.Name).Value.([]string)
+	_, err = reactenv.CompileFileMatchers(fileMatchExpressions)
+
+	if err != nil {
+		c.UI.Error("Invalid '--match' value.\n")
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.exitWithHelp()
+	}
+
+	reporters, err := ReportersFromFlags(flags)
+
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid '--reporter' value, %v.", err))
+		c.exitWithHelp()
+	}
+
+	renv := c.scan(ctx, flags, pathToAssets)
+
+	if len(renv.Files) == 0 {
+		c.UI.Warn(noFilesFound(renv, pathToAssets))
+		return c.Finish(ReportStatusNoPlaceholders, checkExitNoPlaceholders)
+	}
+
+	if err := c.WriteReporters(reporters, renv, "check", duration.Since()); err != nil {
+		c.UI.Error("Error when writing reports.\n")
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.Exit(ReportStatusError, checkExitError)
+	}
+
+	if renv.OccurrencesTotal == 0 {
+		c.UI.Warn(ui.WrapAtLength(fmt.Sprintf("No reactenv environment variables were found in any of the %d '%s' files within '%s'.\n", renv.FilesMatchTotal, strings.Join(renv.FileMatchExpressions(), "', '"), pathToAssets), 0))
+		c.UI.Warn(ui.WrapAtLength("Possible causes:", 4))
+		c.UI.Warn(ui.WrapAtLength("  - reactenv has already ran on these files (without '--templates', or the templates were removed)", 4))
+		c.UI.Warn(ui.WrapAtLength("  - Environment variables were not replaced with `__reactenv.<name>` during build", 4))
+		c.UI.Warn("")
+		duration.In(c.UI.WarnColor, "")
+		return c.Finish(ReportStatusNoPlaceholders, checkExitNoPlaceholders)
+	}
+
+	c.OutputOccurrences(renv)
+	envValuesMissing := c.OutputChecklist(renv)
+
+	if envValuesMissing > 0 {
+		c.UI.Error(fmt.Sprintf("Environment %s not set. See above checklist for missing values.", ui.Pluralize("variable", envValuesMissing)))
+		return c.Finish(ReportStatusMissing, checkExitMissing)
+	}
+
+	if len(renv.OccurrenceErrors) > 0 {
+		c.OutputOccurrenceErrors(renv)
+		return c.Finish(ReportStatusMissing, checkExitMissing)
+	}
+
+	duration.In(c.UI.SuccessColor, "All environment variables are set")
+	return c.Finish(ReportStatusSuccess, checkExitOk)
+}
+
+func (c *CheckCommand) exitWithHelp() {
+	c.UI.Output("\nSee 'reactenv check --help'.")
+	c.Exit(ReportStatusError, checkExitError)
+}
@@ -0,0 +1,107 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hmerritt/reactenv/ui"
+	"github.com/mitchellh/cli"
+)
+
+// Exit code passed to `exit` (which is replaced while running a command)
+type exitCode int
+
+// Returns a command base that does not output anything
+func testBaseCommand() *BaseCommand {
+	c := GetBaseCommand()
+	c.UI = &ui.Ui{ColoredUi: &cli.ColoredUi{Ui: cli.NewMockUi()}, SuccessColor: cli.UiColorGreen}
+	return c
+}
+
+// Runs a command with `args`, returning its exit code (whether it returns or exits)
+func runCommand(t *testing.T, command cli.Command, args []string) (code int) {
+	t.Helper()
+
+	exit = func(code int) { panic(exitCode(code)) }
+	defer func() { exit = os.Exit }()
+
+	defer func() {
+		if r := recover(); r != nil {
+			c, ok := r.(exitCode)
+			if !ok {
+				panic(r)
+			}
+			code = int(c)
+		}
+	}()
+
+	return command.Run(args)
+}
+
+// Writes `files` (relative paths and their contents) within `dir`
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for relPath, contents := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckExitCodes(t *testing.T) {
+	t.Setenv("REACTENV_TEST_SET", "a")
+	t.Setenv("REACTENV_TEST_NUMBER", "abc")
+	os.Unsetenv("REACTENV_TEST_MISSING")
+
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"set/main.js":         `a="__reactenv.REACTENV_TEST_SET"`,
+		"missing/main.js":     `a="__reactenv.REACTENV_TEST_SET",b="__reactenv.REACTENV_TEST_MISSING"`,
+		"invalid/main.js":     `a="__reactenv.REACTENV_TEST_NUMBER:number"`,
+		"none/main.js":        `a=1`,
+		"unmatched/main.css":  `a{color:red}`,
+		"env/main.js":         `a="__reactenv.REACTENV_TEST_FILE"`,
+		"env/.env":            `REACTENV_TEST_FILE=a`,
+		"env-missing/main.js": `a="__reactenv.REACTENV_TEST_SET"`,
+		"match/main.js":       `a="__reactenv.REACTENV_TEST_SET"`,
+		"flag/main.js":        `a="__reactenv.REACTENV_TEST_SET"`,
+	})
+
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"all set", []string{path("set")}, checkExitOk},
+		{"values from --env-file", []string{path("env"), "--env-file", path("env/.env")}, checkExitOk},
+		{"missing value", []string{path("missing")}, checkExitMissing},
+		{"invalid value", []string{path("invalid")}, checkExitMissing},
+		{"no placeholders", []string{path("none")}, checkExitNoPlaceholders},
+		{"no matching files", []string{path("unmatched")}, checkExitNoPlaceholders},
+		{"no PATH", []string{}, checkExitError},
+		{"PATH does not exist", []string{path("not-found")}, checkExitError},
+		{"--env-file does not exist", []string{path("env-missing"), "--env-file", path("env-missing/.env")}, checkExitError},
+		{"invalid --match", []string{path("match"), "--match", "("}, checkExitError},
+		{"flag not used by check", []string{path("flag"), "--out", path("out")}, checkExitError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			command := &CheckCommand{BaseCommand: testBaseCommand()}
+
+			if got := runCommand(t, command, test.args); got != test.want {
+				t.Errorf("reactenv check %q exited with %d, want %d", test.args, got, test.want)
+			}
+		})
+	}
+}
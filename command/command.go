@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/hmerritt/reactenv/ui"
 	"github.com/hmerritt/reactenv/version"
 
 	"github.com/mitchellh/cli"
@@ -14,6 +15,21 @@ func Run() {
 	app := cli.NewCLI("reactenv", version.GetVersion().VersionNumber())
 	app.Args = os.Args[1:]
 
+	// Set before anything is output (flags are parsed once a command runs)
+	if flagSetInArgs(app.Args, flagNoColor.Name) {
+		ui.SetNoColor(true)
+	}
+	if flagSetInArgs(app.Args, flagASCII.Name) {
+		ui.SetASCII(true)
+	}
+
+	// Keep stdout for machine-readable output (e.g. '--format json')
+	if IsMachineOutput(app.Args) {
+		version.FprintTitle(os.Stderr)
+	} else {
+		version.PrintTitle()
+	}
+
 	// Feed active commands to CLI app
 	app.Commands = map[string]cli.CommandFactory{
 		"run": func() (cli.Command, error) {
@@ -21,6 +37,21 @@ func Run() {
 				BaseCommand: GetBaseCommand(),
 			}, nil
 		},
+		"check": func() (cli.Command, error) {
+			return &CheckCommand{
+				BaseCommand: GetBaseCommand(),
+			}, nil
+		},
+		"list": func() (cli.Command, error) {
+			return &ListCommand{
+				BaseCommand: GetBaseCommand(),
+			}, nil
+		},
+		"restore": func() (cli.Command, error) {
+			return &RestoreCommand{
+				BaseCommand: GetBaseCommand(),
+			}, nil
+		},
 	}
 
 	// Run app
@@ -0,0 +1,339 @@
+package command
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hmerritt/reactenv/reactenv"
+	"github.com/hmerritt/reactenv/ui"
+)
+
+// Values of `--group-by`, the first is the default
+var listGroupBy = []string{"key", "file"}
+
+// Values of `--format`, the first is the default
+var listFormats = []string{"table", "json", "csv"}
+
+// Occurrences of one key in one file (or one file for a key, depending on grouping)
+type listEntry struct {
+	Name string
+	// Byte offsets of each occurrence (`Occurrence.StartEnd`)
+	Offsets [][]int
+}
+
+// A key (and every file using it), or a file (and every key used in it)
+type listGroup struct {
+	Name        string
+	Occurrences int
+	Entries     []*listEntry
+}
+
+// JSON of a listed key (when grouped by key), or a key used in a file (when grouped by file)
+type listKeyJSON struct {
+	Key         string         `json:"key"`
+	Occurrences int            `json:"occurrences"`
+	Offsets     [][]int        `json:"offsets,omitempty"`
+	Files       []listFileJSON `json:"files,omitempty"`
+}
+
+// JSON of a listed file (when grouped by file), or a file using a key (when grouped by key)
+type listFileJSON struct {
+	Path        string        `json:"path"`
+	Occurrences int           `json:"occurrences"`
+	Offsets     [][]int       `json:"offsets,omitempty"`
+	Keys        []listKeyJSON `json:"keys,omitempty"`
+}
+
+type ListCommand struct {
+	*BaseCommand
+}
+
+func (c *ListCommand) Synopsis() string {
+	return "List every environment variable, and the files using them"
+}
+
+func (c *ListCommand) Help() string {
+	helpText := fmt.Sprintf(`
+Usage: reactenv list [options] PATH
+
+List every environment variable used in a built react app, with the files that
+use each one (or the environment variables used in each file, with '--group-by file').
+Shows how many times each is used, and the byte offsets of each occurrence.
+No files are written.
+
+Example:
+  $ reactenv list ./dist/assets
+  $ reactenv list ./dist/assets --group-by file --format json
+
+Options:
+%s
+`, c.Flags().Help())
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ListCommand) Flags() *FlagMap {
+	return GetFlagMap(JoinFlagNames(FlagNamesGlobal, FlagNamesScan, []string{flagGroupBy.Name, flagFormat.Name}))
+}
+
+func (c *ListCommand) Run(args []string) int {
+	ctx, stop := c.SignalContext()
+	defer stop()
+
+	c.InitReport("list", args)
+
+	flags := c.Flags()
+	args = flags.Parse(c.UI, args)
+
+	if len(args) == 0 {
+		c.UI.Error("No asset PATH entered.")
+		c.exitWithHelp()
+	}
+
+	pathToAssets := args[0]
+
+	if _, err := os.Stat(pathToAssets); os.IsNotExist(err) {
+		c.UI.Error(fmt.Sprintf("File PATH '%s' does not exist.", pathToAssets))
+		c.exitWithHelp()
+	}
+
+	groupBy := flags.Get(flagGroupBy.Name).Value.(string)
+	if groupBy == "" {
+		groupBy = listGroupBy[0]
+	}
+
+	if !slices.Contains(listGroupBy, groupBy) {
+		c.UI.Error(fmt.Sprintf("Invalid '--group-by' value '%s', must be one of '%s'.", groupBy, strings.Join(listGroupBy, "', '")))
+		c.exitWithHelp()
+	}
+
+	format := flags.Get(flagFormat.Name).Value.(string)
+	if format == "" {
+		format = listFormats[0]
+	}
+
+	if !slices.Contains(listFormats, format) {
+		c.UI.Error(fmt.Sprintf("Invalid '--format' value '%s', must be one of '%s'.", format, strings.Join(listFormats, "', '")))
+		c.exitWithHelp()
+	}
+
+	// The list is included in the report instead (everything else is written to stderr)
+	if c.Report != nil && format != listFormats[0] {
+		c.UI.Error(fmt.Sprintf("'--format %s' can not be used with '--output json', the list is included in the report.", format))
+		c.exitWithHelp()
+	}
+
+	fileMatchExpressions := flags.Get(flagMatch.Name).Value.([]string)
+	_, err := reactenv.CompileFileMatchers(fileMatchExpressions)
+
+	if err != nil {
+		c.UI.Error("Invalid '--match' value.\n")
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.exitWithHelp()
+	}
+
+	renv := c.scan(ctx, flags, pathToAssets)
+
+	if len(renv.Files) == 0 {
+		c.UI.Error(noFilesFound(renv, pathToAssets))
+		c.Exit(ReportStatusNoPlaceholders, 1)
+	}
+
+	if renv.OccurrencesTotal == 0 {
+		c.UI.Warn(ui.WrapAtLength(fmt.Sprintf("No reactenv environment variables were found in any of the %d '%s' files within '%s'.\n", renv.FilesMatchTotal, strings.Join(renv.FileMatchExpressions(), "', '"), pathToAssets), 0))
+	}
+
+	var groups []*listGroup
+	if groupBy == "file" {
+		groups = listGroupsByFile(renv)
+	} else {
+		groups = listGroupsByKey(renv)
+	}
+
+	if c.Report != nil {
+		c.Report.List = listDocument(groups, groupBy)
+	}
+
+	switch format {
+	case "json":
+		err = c.outputJSON(groups, groupBy)
+	case "csv":
+		err = c.outputCSV(groups, groupBy)
+	default:
+		c.outputTable(groups, groupBy)
+	}
+
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.Exit(ReportStatusError, 1)
+	}
+
+	if renv.OccurrencesTotal == 0 {
+		return c.Finish(ReportStatusNoPlaceholders, 0)
+	}
+
+	return c.Finish(ReportStatusSuccess, 0)
+}
+
+// Returns every key (sorted), with the files using it (in the order they were found)
+func listGroupsByKey(renv *reactenv.Reactenv) []*listGroup {
+	groups := make([]*listGroup, 0, len(renv.OccurrenceKeys))
+	groupsByKey := make(map[string]*listGroup, len(renv.OccurrenceKeys))
+
+	for _, key := range renv.OccurrenceKeysSorted() {
+		groupsByKey[key] = &listGroup{Name: key}
+		groups = append(groups, groupsByKey[key])
+	}
+
+	for fileIndex, fileOccurrences := range renv.OccurrencesByFile {
+		entries := make(map[string]*listEntry)
+		for _, occurrence := range fileOccurrences.Occurrences {
+			group := groupsByKey[occurrence.Key]
+			entry, ok := entries[occurrence.Key]
+			if !ok {
+				entry = &listEntry{Name: renv.Files[fileIndex].Path}
+				entries[occurrence.Key] = entry
+				group.Entries = append(group.Entries, entry)
+			}
+			entry.Offsets = append(entry.Offsets, occurrence.StartEnd)
+			group.Occurrences++
+		}
+	}
+
+	return groups
+}
+
+// Returns every file with occurrences (in the order they were found), with the keys used in it (sorted)
+func listGroupsByFile(renv *reactenv.Reactenv) []*listGroup {
+	groups := make([]*listGroup, 0, len(renv.Files))
+
+	for fileIndex, fileOccurrences := range renv.OccurrencesByFile {
+		if len(fileOccurrences.Occurrences) == 0 {
+			continue
+		}
+
+		group := &listGroup{Name: renv.Files[fileIndex].Path, Occurrences: len(fileOccurrences.Occurrences)}
+		entries := make(map[string]*listEntry)
+
+		for _, occurrence := range fileOccurrences.Occurrences {
+			entry, ok := entries[occurrence.Key]
+			if !ok {
+				entry = &listEntry{Name: occurrence.Key}
+				entries[occurrence.Key] = entry
+				group.Entries = append(group.Entries, entry)
+			}
+			entry.Offsets = append(entry.Offsets, occurrence.StartEnd)
+		}
+
+		slices.SortFunc(group.Entries, func(a, b *listEntry) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// Outputs each group, with the occurrences and byte offsets of each entry
+func (c *ListCommand) outputTable(groups []*listGroup, groupBy string) {
+	for _, group := range groups {
+		if groupBy == "file" {
+			c.UI.Output(fmt.Sprintf("%s (%d %s of %d environment %s):", group.Name, group.Occurrences, ui.Pluralize("occurrence", group.Occurrences), len(group.Entries), ui.Pluralize("variable", len(group.Entries))))
+		} else {
+			c.UI.Output(fmt.Sprintf("%s (%d %s in %d %s):", group.Name, group.Occurrences, ui.Pluralize("occurrence", group.Occurrences), len(group.Entries), ui.Pluralize("file", len(group.Entries))))
+		}
+
+		for _, entry := range group.Entries {
+			offsets := make([]string, 0, len(entry.Offsets))
+			for _, startEnd := range entry.Offsets {
+				offsets = append(offsets, fmt.Sprintf("%d-%d", startEnd[0], startEnd[1]))
+			}
+
+			name := entry.Name
+			if groupBy == "key" {
+				name = "in " + name
+			}
+
+			c.UI.Output(ui.WrapAtLength(fmt.Sprintf("  - %4dx %s (at %s %s)", len(entry.Offsets), name, ui.Pluralize("byte", len(entry.Offsets)), strings.Join(offsets, ", ")), 10))
+		}
+
+		c.UI.Output("")
+	}
+}
+
+// Returns every group as JSON keys (or files, when grouped by file)
+func listDocument(groups []*listGroup, groupBy string) any {
+	if groupBy == "file" {
+		files := make([]listFileJSON, 0, len(groups))
+		for _, group := range groups {
+			keys := make([]listKeyJSON, 0, len(group.Entries))
+			for _, entry := range group.Entries {
+				keys = append(keys, listKeyJSON{Key: entry.Name, Occurrences: len(entry.Offsets), Offsets: entry.Offsets})
+			}
+			files = append(files, listFileJSON{Path: group.Name, Occurrences: group.Occurrences, Keys: keys})
+		}
+		return files
+	}
+
+	keys := make([]listKeyJSON, 0, len(groups))
+	for _, group := range groups {
+		files := make([]listFileJSON, 0, len(group.Entries))
+		for _, entry := range group.Entries {
+			files = append(files, listFileJSON{Path: entry.Name, Occurrences: len(entry.Offsets), Offsets: entry.Offsets})
+		}
+		keys = append(keys, listKeyJSON{Key: group.Name, Occurrences: group.Occurrences, Files: files})
+	}
+	return keys
+}
+
+// Outputs every group as a JSON array
+func (c *ListCommand) outputJSON(groups []*listGroup, groupBy string) error {
+	output, err := json.MarshalIndent(listDocument(groups, groupBy), "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	c.UI.Output(string(output))
+	return nil
+}
+
+// Outputs every occurrence as a CSV row (ordered by group)
+func (c *ListCommand) outputCSV(groups []*listGroup, groupBy string) error {
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+
+	if groupBy == "file" {
+		writer.Write([]string{"file", "key", "start", "end"})
+	} else {
+		writer.Write([]string{"key", "file", "start", "end"})
+	}
+
+	for _, group := range groups {
+		for _, entry := range group.Entries {
+			for _, startEnd := range entry.Offsets {
+				writer.Write([]string{group.Name, entry.Name, strconv.Itoa(startEnd[0]), strconv.Itoa(startEnd[1])})
+			}
+		}
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	c.UI.Output(strings.TrimSuffix(output.String(), "\n"))
+	return nil
+}
+
+func (c *ListCommand) exitWithHelp() {
+	c.UI.Output("\nSee 'reactenv list --help'.")
+	c.Exit(ReportStatusError, 1)
+}
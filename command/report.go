@@ -0,0 +1,252 @@
+package command
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hmerritt/reactenv/reactenv"
+	"github.com/hmerritt/reactenv/ui"
+
+	"github.com/mattn/go-colorable"
+	"github.com/mitchellh/cli"
+)
+
+// Status of a command, in its report
+const (
+	ReportStatusSuccess        = "success"
+	ReportStatusMissing        = "missing"
+	ReportStatusNoPlaceholders = "no_placeholders"
+	ReportStatusError          = "error"
+)
+
+// Matches ANSI color codes, which are removed from errors in a report
+var ansiExpression = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Machine-readable report of a command, written to stdout as JSON when
+// `--output json` is used. Never contains environment variable values.
+type Report struct {
+	Command     string       `json:"command"`
+	Status      string       `json:"status"`
+	Path        string       `json:"path,omitempty"`
+	DryRun      bool         `json:"dry_run,omitempty"`
+	OutDir      string       `json:"out_dir,omitempty"`
+	Files       []ReportFile `json:"files"`
+	Occurrences int          `json:"occurrences"`
+	Keys        ReportKeys   `json:"keys"`
+
+	// Files written alongside injected files (see `reactenv.Reactenv`)
+	SourceMaps     []string `json:"source_maps,omitempty"`
+	CSPFiles       []string `json:"csp_files,omitempty"`
+	CSPHeaderFile  string   `json:"csp_header_file,omitempty"`
+	IntegrityFiles []string `json:"integrity_files,omitempty"`
+	PrecacheFiles  []string `json:"precache_files,omitempty"`
+
+	// Files restored by `reactenv restore`
+	Restored []string `json:"restored,omitempty"`
+
+	// Every key (or file, with `--group-by file`) listed by `reactenv list`
+	List any `json:"list,omitempty"`
+
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+
+	// Duration of each step, and the whole command ("total"), in milliseconds
+	Durations map[string]float64 `json:"durations_ms"`
+
+	duration *ui.Duration
+	written  bool
+}
+
+// A file with environment variables, in a report
+type ReportFile struct {
+	Path        string   `json:"path"`
+	Occurrences int      `json:"occurrences"`
+	Keys        []string `json:"keys"`
+}
+
+// Every environment variable key, by how its value was resolved (sorted)
+type ReportKeys struct {
+	// Value has been set
+	Resolved []string `json:"resolved"`
+	// Not set, using a default value
+	Default []string `json:"default"`
+	// Optional, not set and left empty
+	Optional []string `json:"optional"`
+	// Required, not set
+	Missing []string `json:"missing"`
+	// Value can not be injected (e.g. not valid for its type)
+	Invalid []string `json:"invalid"`
+}
+
+// Wraps the UI of a command, so errors are added to its report
+type reportUi struct {
+	cli.Ui
+	report *Report
+}
+
+func (u *reportUi) Error(message string) {
+	if line := reportMessage(message); line != "" {
+		u.report.Errors = append(u.report.Errors, line)
+	}
+	u.Ui.Error(message)
+}
+
+func (u *reportUi) Warn(message string) {
+	if line := reportMessage(message); line != "" {
+		u.report.Warnings = append(u.report.Warnings, strings.TrimPrefix(line, "Warning: "))
+	}
+	u.Ui.Warn(message)
+}
+
+// Returns `message` as a single line without colors (undoing any wrapping)
+func reportMessage(message string) string {
+	return strings.Join(strings.Fields(ansiExpression.ReplaceAllString(message, "")), " ")
+}
+
+// Starts a report of `command` when `--output json` is used (checked before
+// flags are parsed, as output may be written while parsing). All other output
+// is written to stderr instead, leaving stdout for the report.
+func (c *BaseCommand) InitReport(command string, args []string) {
+	switch output := flagValueFromArgs(args, flagOutput.Name); output {
+	case "", "text":
+		return
+	case "json":
+	default:
+		c.UI.Error(fmt.Sprintf("Invalid '--output' value '%s', must be one of 'text', 'json'.", output))
+		exit(c.ErrorCode)
+	}
+
+	c.Report = &Report{
+		Command:   command,
+		Files:     make([]ReportFile, 0),
+		Keys:      ReportKeys{Resolved: []string{}, Default: []string{}, Optional: []string{}, Missing: []string{}, Invalid: []string{}},
+		Errors:    make([]string, 0),
+		Warnings:  make([]string, 0),
+		Durations: make(map[string]float64),
+		duration:  ui.InitDuration(c.UI),
+	}
+
+	// Everything else is output to stderr, so colors depend on stderr (not stdout)
+	if !flagSetInArgs(args, flagNoColor.Name) {
+		ui.SetNoColor(!ui.ColorEnabled(os.Stderr))
+	}
+
+	c.UI.Ui = &reportUi{
+		Ui: &cli.BasicUi{
+			Reader:      bufio.NewReader(os.Stdin),
+			Writer:      colorable.NewColorableStderr(),
+			ErrorWriter: colorable.NewColorableStderr(),
+		},
+		report: c.Report,
+	}
+}
+
+// Writes the report (if there is one) with `status`, and returns `code` (the exit code)
+func (c *BaseCommand) Finish(status string, code int) int {
+	if c.Report == nil || c.Report.written {
+		return code
+	}
+
+	c.Report.Status = status
+	c.Report.Durations["total"] = durationMs(c.Report.duration)
+	c.Report.written = true
+
+	output, err := json.MarshalIndent(c.Report, "", "  ")
+
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("%v", err))
+		return c.ErrorCode
+	}
+
+	fmt.Fprintln(os.Stdout, string(output))
+	return code
+}
+
+// Exits the process with a code (replaced in tests, to run commands without exiting)
+var exit = os.Exit
+
+// Writes the report (if there is one) with `status`, and exits with `code`
+func (c *BaseCommand) Exit(status string, code int) {
+	exit(c.Finish(status, code))
+}
+
+// Adds the duration of a step to the report
+func (r *Report) Time(step string, duration *ui.Duration) {
+	if r == nil {
+		return
+	}
+	r.Durations[step] = durationMs(duration)
+}
+
+// Adds the files, occurrences and keys found by `renv` to the report
+func (r *Report) SetOccurrences(renv *reactenv.Reactenv) {
+	if r == nil {
+		return
+	}
+
+	r.Path = renv.Dir
+	r.Occurrences = renv.OccurrencesTotal
+	r.Files = make([]ReportFile, 0, len(renv.Files))
+
+	for fileIndex, fileOccurrences := range renv.OccurrencesByFile {
+		if len(fileOccurrences.Occurrences) == 0 {
+			continue
+		}
+
+		keys := make([]string, 0)
+		for _, occurrence := range fileOccurrences.Occurrences {
+			if !slices.Contains(keys, occurrence.Key) {
+				keys = append(keys, occurrence.Key)
+			}
+		}
+
+		slices.Sort(keys)
+		r.Files = append(r.Files, ReportFile{Path: renv.Files[fileIndex].Path, Occurrences: len(fileOccurrences.Occurrences), Keys: keys})
+	}
+
+	for _, key := range renv.OccurrenceKeysSorted() {
+		_, isSet := renv.OccurrenceKeysReplacement[key]
+		switch {
+		case isSet:
+			r.Keys.Resolved = append(r.Keys.Resolved, key)
+		case renv.OccurrenceKeysRequired[key]:
+			r.Keys.Missing = append(r.Keys.Missing, key)
+		case renv.OccurrenceKeysDefault[key]:
+			r.Keys.Default = append(r.Keys.Default, key)
+		default:
+			r.Keys.Optional = append(r.Keys.Optional, key)
+		}
+	}
+
+	for _, occurrenceError := range renv.OccurrenceErrors {
+		if !slices.Contains(r.Keys.Invalid, occurrenceError.Occurrence.Key) {
+			r.Keys.Invalid = append(r.Keys.Invalid, occurrenceError.Occurrence.Key)
+		}
+	}
+
+	slices.Sort(r.Keys.Invalid)
+}
+
+// Adds the files written alongside injected files to the report
+func (r *Report) SetInjected(renv *reactenv.Reactenv) {
+	if r == nil {
+		return
+	}
+
+	r.OutDir = renv.OutDir
+	r.SourceMaps = renv.SourceMaps
+	r.CSPFiles = renv.CSPFiles
+	r.CSPHeaderFile = renv.CSPHeaderFile
+	r.IntegrityFiles = renv.IntegrityFiles
+	r.PrecacheFiles = renv.PrecacheFiles
+}
+
+// Returns the time since `duration` started, in milliseconds
+func durationMs(duration *ui.Duration) float64 {
+	return float64(duration.Since().Microseconds()) / 1000
+}
@@ -0,0 +1,97 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hmerritt/reactenv/reactenv"
+	"github.com/hmerritt/reactenv/ui"
+)
+
+type RestoreCommand struct {
+	*BaseCommand
+}
+
+func (c *RestoreCommand) Synopsis() string {
+	return "Restore placeholders into files that have been injected"
+}
+
+func (c *RestoreCommand) Help() string {
+	helpText := fmt.Sprintf(`
+Usage: reactenv restore [options] PATH
+  
+Restore the original contents (with '__reactenv.<name>' placeholders) of every
+file injected by 'reactenv run --templates', using the templates kept in '.reactenv'.
+
+Files modified since they were injected are not restored, unless '--force' is used.
+
+Example:
+  $ reactenv restore ./dist/assets
+
+Options:
+%s
+`, c.Flags().Help())
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *RestoreCommand) Flags() *FlagMap {
+	return GetFlagMap(JoinFlagNames(FlagNamesGlobal, []string{flagTemplateDir.Name}))
+}
+
+func (c *RestoreCommand) Run(args []string) int {
+	duration := ui.InitDuration(c.UI)
+
+	ctx, stop := c.SignalContext()
+	defer stop()
+
+	c.InitReport("restore", args)
+
+	flags := c.Flags()
+	args = flags.Parse(c.UI, args)
+
+	if len(args) == 0 {
+		c.UI.Error("No asset PATH entered.")
+		c.exitWithHelp()
+	}
+
+	pathToAssets := args[0]
+
+	if _, err := os.Stat(pathToAssets); os.IsNotExist(err) {
+		c.UI.Error(fmt.Sprintf("File PATH '%s' does not exist.", pathToAssets))
+		c.exitWithHelp()
+	}
+
+	renv := reactenv.NewReactenv()
+	renv.TemplateDir = flags.Get(flagTemplateDir.Name).Value.(string)
+
+	step := ui.InitDuration(c.UI)
+	restored, err := renv.RestoreTemplates(ctx, pathToAssets, flags.Get(flagForce.Name).Value.(bool))
+	c.Report.Time("restore", step)
+
+	if err != nil {
+		c.UI.Error("Error when restoring templates, no files have been changed.\n")
+		c.UI.Error(ui.WrapAtLength(fmt.Sprintf("%v", err), 0))
+		c.Exit(ReportStatusError, 1)
+	}
+
+	c.UI.Output(fmt.Sprintf("Restored %d %s:", len(restored), ui.Pluralize("file", len(restored))))
+	for _, filePath := range restored {
+		c.UI.Output(fmt.Sprintf("  - %s", filePath))
+	}
+	c.UI.Output("")
+
+	if c.Report != nil {
+		c.Report.Path = pathToAssets
+		c.Report.Restored = restored
+	}
+
+	duration.In(c.UI.SuccessColor, "Restored all placeholders")
+	return c.Finish(ReportStatusSuccess, 0)
+}
+
+func (c *RestoreCommand) exitWithHelp() {
+	c.UI.Output("\nSee 'reactenv restore --help'.")
+	c.Exit(ReportStatusError, 1)
+}
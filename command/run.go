@@ -3,13 +3,15 @@ package command
 import (
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
 
 	"github.com/hmerritt/reactenv/reactenv"
 	"github.com/hmerritt/reactenv/ui"
 )
 
+// Bytes of context shown either side of each occurrence, in `--dry-run` diffs
+const diffContextBytes = 24
+
 type RunCommand struct {
 	*BaseCommand
 }
@@ -32,20 +34,37 @@ Example:
     ├── index.css
     ├── index-csxw0qbp%s
     ├── login.lazy-b839zm%s
-    └── user.lazy-c7942lh%s  <- Runs on all %s files in PATH
-`, jsInfo, jsInfo, jsInfo, jsInfo)
+    ├── user.lazy-c7942lh%s  <- Runs on all %s files in PATH (by default)
+    └── chunks
+        └── chart-a82nd1%s   <- Including sub-directories
+
+Files and directories listed in a '.reactenvignore' file (within PATH) are skipped.
+
+With '--templates', the template (original contents) of every injected file is
+kept in '.reactenv' (within PATH), so running again re-injects with new values.
+Use 'reactenv restore' to put the placeholders back.
+
+Options:
+%s
+`, jsInfo, jsInfo, jsInfo, jsInfo, jsInfo, c.Flags().Help())
 
 	return strings.TrimSpace(helpText)
 }
 
 func (c *RunCommand) Flags() *FlagMap {
-	return GetFlagMap(FlagNamesGlobal)
+	return GetFlagMap(JoinFlagNames(FlagNamesGlobal, FlagNamesScan, []string{flagReporter.Name, flagDryRun.Name, flagOut.Name, flagCSPHeaderFile.Name}))
 }
 
 func (c *RunCommand) Run(args []string) int {
 	duration := ui.InitDuration(c.UI)
 
-	args = c.Flags().Parse(c.UI, args)
+	ctx, stop := c.SignalContext()
+	defer stop()
+
+	c.InitReport("run", args)
+
+	flags := c.Flags()
+	args = flags.Parse(c.UI, args)
 
 	if len(args) == 0 {
 		c.UI.Error("No asset PATH entered.")
@@ -53,93 +72,182 @@ func (c *RunCommand) Run(args []string) int {
 	}
 
 	pathToAssets := args[0]
+	dryRun := flags.Get(flagDryRun.Name).Value.(bool)
+
+	if c.Report != nil {
+		c.Report.DryRun = dryRun
+	}
 
 	if _, err := os.Stat(pathToAssets); os.IsNotExist(err) {
 		c.UI.Error(fmt.Sprintf("File PATH '%s' does not exist.", pathToAssets))
 		c.exitWithHelp()
 	}
 
-	// @TODO: Add flag to specify matcher
-	fileMatchExpression := `.*\.js`
-	_, err := regexp.Compile(fileMatchExpression)
+	fileMatchExpressions := flags.Get(flagMatch.Name).Value.([]string)
+	_, err := reactenv.CompileFileMatchers(fileMatchExpressions)
 
 	if err != nil {
-		c.UI.Error(fmt.Sprintf("File match expression '%s' is not valid.\n", fileMatchExpression))
+		c.UI.Error("Invalid '--match' value.\n")
 		c.UI.Error(fmt.Sprintf("%v", err))
 		c.exitWithHelp()
 	}
 
-	renv := reactenv.NewReactenv(c.UI)
-
-	err = renv.FindFiles(pathToAssets, fileMatchExpression)
+	reporters, err := ReportersFromFlags(flags)
 
 	if err != nil {
-		c.UI.Error(fmt.Sprintf("Error reading files in PATH '%s'.\n", pathToAssets))
-		c.UI.Error(fmt.Sprintf("%v", err))
-		os.Exit(1)
+		c.UI.Error(fmt.Sprintf("Invalid '--reporter' value, %v.", err))
+		c.exitWithHelp()
 	}
 
+	renv := c.scan(ctx, flags, pathToAssets)
+
 	if len(renv.Files) == 0 {
-		c.UI.Error(fmt.Sprintf("No files found in path '%s' using matcher '%s'", pathToAssets, fileMatchExpression))
-		os.Exit(1)
+		c.UI.Error(noFilesFound(renv, pathToAssets))
+		c.Exit(ReportStatusNoPlaceholders, 1)
 	}
 
-	renv.FindOccurrences()
+	if err := c.WriteReporters(reporters, renv, "run", duration.Since()); err != nil {
+		c.UI.Error("Error when writing reports.\n")
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.Exit(ReportStatusError, 1)
+	}
 
 	if renv.OccurrencesTotal == 0 {
-		c.UI.Warn(ui.WrapAtLength(fmt.Sprintf("No reactenv environment variables were found in any of the %d '%s' files within '%s', therefore nothing was injected.\n", renv.FilesMatchTotal, fileMatchExpression, pathToAssets), 0))
+		c.UI.Warn(ui.WrapAtLength(fmt.Sprintf("No reactenv environment variables were found in any of the %d '%s' files within '%s', therefore nothing was injected.\n", renv.FilesMatchTotal, strings.Join(renv.FileMatchExpressions(), "', '"), pathToAssets), 0))
 		c.UI.Warn(ui.WrapAtLength("Possible causes:", 4))
-		c.UI.Warn(ui.WrapAtLength("  - reactenv has already ran on these files", 4))
+		c.UI.Warn(ui.WrapAtLength("  - reactenv has already ran on these files (without '--templates', or the templates were removed)", 4))
 		c.UI.Warn(ui.WrapAtLength("  - Environment variables were not replaced with `__reactenv.<name>` during build", 4))
 		c.UI.Warn("")
 		duration.In(c.UI.WarnColor, "")
-		return 1
-	}
-
-	c.UI.Output(
-		fmt.Sprintf(
-			"Found %d reactenv environment %s in %d/%d matching files:",
-			renv.OccurrencesTotal,
-			ui.Pluralize("variable", renv.OccurrencesTotal),
-			len(renv.Files),
-			renv.FilesMatchTotal,
-		),
-	)
-	for fileIndex, fileOccurrencesTotal := range renv.OccurrencesByFile {
-		c.UI.Output(
-			fmt.Sprintf(
-				"  - %4dx in %s",
-				len(fileOccurrencesTotal.Occurrences),
-				(*renv.Files[fileIndex]).Name(),
-			),
-		)
-	}
-	c.UI.Output("")
-
-	c.UI.Output(fmt.Sprintf("Environment %s checklist (ticked if value has been set):", ui.Pluralize("variable", renv.OccurrencesTotal)))
-	envValuesMissing := 0
-	for occurrenceKey := range renv.OccurrenceKeys {
-		check := "✅"
-		if _, ok := renv.OccurrenceKeysReplacement[occurrenceKey]; !ok {
-			check = "❌"
-			envValuesMissing++
-		}
-		c.UI.Output(fmt.Sprintf("  - %4s %s", check, occurrenceKey))
+		return c.Finish(ReportStatusNoPlaceholders, 1)
+	}
+
+	c.OutputOccurrences(renv)
+	envValuesMissing := c.OutputChecklist(renv)
+
+	if dryRun {
+		c.outputDiff(renv)
 	}
-	c.UI.Output("")
 
 	if envValuesMissing > 0 {
 		c.UI.Error(fmt.Sprintf("Environment %s not set. See above checklist for missing values.", ui.Pluralize("variable", envValuesMissing)))
-		os.Exit(1)
+		if dryRun {
+			c.UI.Warn("Dry run, no files were written.")
+		}
+		c.Exit(ReportStatusMissing, 1)
+	}
+
+	if len(renv.OccurrenceErrors) > 0 {
+		c.OutputOccurrenceErrors(renv)
+		if dryRun {
+			c.UI.Warn("Dry run, no files were written.")
+		}
+		c.Exit(ReportStatusMissing, 1)
+	}
+
+	if dryRun {
+		duration.In(c.UI.SuccessColor, "Dry run complete, no files were written")
+		return c.Finish(ReportStatusSuccess, 0)
+	}
+
+	step := ui.InitDuration(c.UI)
+	err = renv.ReplaceOccurrences(ctx)
+	c.Report.Time("inject", step)
+
+	if err != nil {
+		c.UI.Error("Error when injecting environment variables.\n")
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.Exit(ReportStatusError, 1)
+	}
+
+	c.Report.SetInjected(renv)
+
+	if len(renv.SourceMaps) > 0 {
+		c.UI.Output(fmt.Sprintf("Adjusted %d source %s to match:", len(renv.SourceMaps), ui.Pluralize("map", len(renv.SourceMaps))))
+		for _, sourceMap := range renv.SourceMaps {
+			c.UI.Output(fmt.Sprintf("  - %s", sourceMap))
+		}
+		c.UI.Output("")
+	}
+
+	if len(renv.CSPFiles) > 0 {
+		c.UI.Output(fmt.Sprintf("Updated Content-Security-Policy hashes in %d HTML %s:", len(renv.CSPFiles), ui.Pluralize("file", len(renv.CSPFiles))))
+		for _, cspFile := range renv.CSPFiles {
+			c.UI.Output(fmt.Sprintf("  - %s", cspFile))
+		}
+		c.UI.Output("")
+	}
+
+	if renv.CSPHeaderFile != "" {
+		c.UI.Output(fmt.Sprintf("Wrote Content-Security-Policy hashes to '%s'", renv.CSPHeaderFile))
+		c.UI.Output("")
+	}
+
+	if len(renv.IntegrityFiles) > 0 {
+		c.UI.Output(fmt.Sprintf("Updated integrity hashes in %d HTML %s:", len(renv.IntegrityFiles), ui.Pluralize("file", len(renv.IntegrityFiles))))
+		for _, integrityFile := range renv.IntegrityFiles {
+			c.UI.Output(fmt.Sprintf("  - %s", integrityFile))
+		}
+		c.UI.Output("")
+	}
+
+	if len(renv.PrecacheFiles) > 0 {
+		c.UI.Output(fmt.Sprintf("Updated precache revisions in %d service worker %s:", len(renv.PrecacheFiles), ui.Pluralize("file", len(renv.PrecacheFiles))))
+		for _, precacheFile := range renv.PrecacheFiles {
+			c.UI.Output(fmt.Sprintf("  - %s", precacheFile))
+		}
+		c.UI.Output("")
+	}
+
+	for _, warning := range renv.Warnings {
+		c.UI.Warn(ui.WrapAtLength(fmt.Sprintf("Warning: %s", warning), 0))
+	}
+	if len(renv.Warnings) > 0 {
+		c.UI.Warn("")
 	}
 
-	renv.ReplaceOccurrences()
+	if renv.OutDir != "" {
+		duration.In(c.UI.SuccessColor, fmt.Sprintf("Injected all environment variables into '%s'", renv.OutDir))
+		return c.Finish(ReportStatusSuccess, 0)
+	}
 
 	duration.In(c.UI.SuccessColor, fmt.Sprintf("Injected all environment variables"))
-	return 0
+	return c.Finish(ReportStatusSuccess, 0)
+}
+
+// Outputs a diff of every occurrence (without writing any files)
+func (c *RunCommand) outputDiff(renv *reactenv.Reactenv) {
+	err := renv.FilesWalk(func(fileIndex int, file *reactenv.File, filePath string) error {
+		hunks, err := renv.DiffFile(file, renv.OccurrencesByFile[fileIndex].Occurrences, diffContextBytes)
+
+		if err != nil {
+			return err
+		}
+
+		c.UI.Output(c.UI.Colorize(fmt.Sprintf("--- a/%s", file.Path), c.UI.ErrorColor))
+		c.UI.Output(c.UI.Colorize(fmt.Sprintf("+++ b/%s", file.Path), c.UI.SuccessColor))
+
+		for _, hunk := range hunks {
+			c.UI.Output(c.UI.Colorize(fmt.Sprintf("@@ -%d:%d +%d:%d @@ %s", hunk.Line, hunk.Column, hunk.Line, hunk.Column, hunk.Occurrence.Key), c.UI.InfoColor))
+			c.UI.Output(c.UI.Colorize("-"+hunk.Before, c.UI.ErrorColor))
+			if hunk.Err != nil {
+				c.UI.Warn(fmt.Sprintf("! %v", hunk.Err))
+			} else {
+				c.UI.Output(c.UI.Colorize("+"+hunk.After, c.UI.SuccessColor))
+			}
+		}
+
+		c.UI.Output("")
+		return nil
+	})
+
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("%v", err))
+		c.Exit(ReportStatusError, 1)
+	}
 }
 
 func (c *RunCommand) exitWithHelp() {
 	c.UI.Output("\nSee 'reactenv run --help'.")
-	os.Exit(1)
+	c.Exit(ReportStatusError, 1)
 }
@@ -3,10 +3,13 @@ module github.com/hmerritt/reactenv
 go 1.23.4
 
 require (
+	github.com/andybalholm/brotli v1.2.6
 	github.com/briandowns/spinner v1.23.1
 	github.com/fatih/color v1.18.0
 	github.com/jessevdk/go-flags v1.6.1
 	github.com/magefile/mage v1.15.0
+	github.com/mattn/go-colorable v0.1.13
+	github.com/mattn/go-isatty v0.0.20
 	github.com/mitchellh/cli v1.1.5
 	github.com/mitchellh/gox v1.0.1
 	github.com/posener/complete v1.2.3
@@ -30,8 +33,6 @@ require (
 	github.com/hashicorp/go-version v1.0.0 // indirect
 	github.com/huandu/xstrings v1.3.2 // indirect
 	github.com/imdario/mergo v0.3.11 // indirect
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
 	github.com/mitchellh/copystructure v1.0.0 // indirect
 	github.com/mitchellh/iochan v1.0.0 // indirect
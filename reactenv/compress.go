@@ -0,0 +1,302 @@
+package reactenv
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Compression of a file, e.g. a precompressed `.gz` asset
+type Compression = string
+
+const (
+	CompressionNone   Compression = ""
+	CompressionGzip   Compression = "gzip"
+	CompressionBrotli Compression = "br"
+)
+
+// Compressions with a file extension, in the order siblings are regenerated
+var compressionExtensions = []struct {
+	Compression Compression
+	Extension   string
+}{
+	{CompressionGzip, ".gz"},
+	{CompressionBrotli, ".br"},
+}
+
+// Returns the compression of a file (by extension), and its path without the extension
+func CompressionFromPath(filePath string) (Compression, string) {
+	for _, c := range compressionExtensions {
+		if strings.HasSuffix(filePath, c.Extension) && len(filePath) > len(c.Extension) {
+			return c.Compression, strings.TrimSuffix(filePath, c.Extension)
+		}
+	}
+	return CompressionNone, filePath
+}
+
+// Returns the compression of a precompressed asset with no uncompressed file
+// next to it, and the path of the (missing) uncompressed file. Other files
+// return `CompressionNone`, and their own path.
+func (r *Reactenv) compressedOnly(filePath string, relPath string) (Compression, string) {
+	compression, plainRelPath := CompressionFromPath(relPath)
+
+	if compression == CompressionNone {
+		return CompressionNone, relPath
+	}
+
+	plainPath, _ := strings.CutSuffix(filePath, filepath.Ext(filePath))
+
+	if _, err := os.Lstat(plainPath); !errors.Is(err, fs.ErrNotExist) {
+		return CompressionNone, relPath
+	}
+
+	return compression, plainRelPath
+}
+
+// Settings of a compressed file, so it can be recompressed to match
+type compressionOptions struct {
+	compression Compression
+	// gzip level, or brotli quality
+	level int
+	// brotli window size (log2)
+	window int
+	// gzip header of the original file (name, comment and modification time are kept)
+	header gzip.Header
+}
+
+// Reads the settings of a compressed file.
+//
+// The gzip level is taken from the `XFL` header field (which records best
+// compression, fastest, or neither). Brotli streams do not record their
+// quality, so the highest quality (which build tools use by default) is used,
+// with the same window size.
+func readCompressionOptions(filePath string, compression Compression) (compressionOptions, error) {
+	options := compressionOptions{compression: compression}
+
+	file, err := os.Open(filePath)
+
+	if err != nil {
+		return options, err
+	}
+
+	defer file.Close()
+
+	header := make([]byte, 10)
+	n, err := io.ReadFull(file, header)
+
+	switch compression {
+	case CompressionGzip:
+		if err != nil || header[0] != 0x1f || header[1] != 0x8b {
+			return options, errors.New("not a valid gzip file")
+		}
+
+		switch header[8] {
+		case 2:
+			options.level = gzip.BestCompression
+		case 4:
+			options.level = gzip.BestSpeed
+		default:
+			options.level = gzip.DefaultCompression
+		}
+
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return options, err
+		}
+
+		reader, err := gzip.NewReader(file)
+
+		if err != nil {
+			return options, err
+		}
+
+		defer reader.Close()
+
+		options.header = reader.Header
+
+	case CompressionBrotli:
+		if n == 0 {
+			return options, errors.New("not a valid brotli file")
+		}
+
+		options.level = brotli.BestCompression
+		options.window, err = brotliWindow(header[0])
+
+		if err != nil {
+			return options, err
+		}
+	}
+
+	return options, nil
+}
+
+// Returns the highest compression settings (used when there is no file to match)
+func defaultCompressionOptions(compression Compression) compressionOptions {
+	if compression == CompressionBrotli {
+		return compressionOptions{compression: compression, level: brotli.BestCompression, window: 22}
+	}
+	return compressionOptions{compression: compression, level: gzip.BestCompression}
+}
+
+// Decodes the window size (`WBITS`) from the first byte of a brotli stream (RFC 7932, section 9.1)
+func brotliWindow(b byte) (int, error) {
+	if b&1 == 0 {
+		return 16, nil
+	}
+	if n := int(b>>1) & 7; n != 0 {
+		return 17 + n, nil
+	}
+	switch m := int(b>>4) & 7; m {
+	case 0:
+		return 17, nil
+	case 1:
+		return 0, errors.New("brotli large window streams are not supported")
+	default:
+		return 8 + m, nil
+	}
+}
+
+// Returns a writer that compresses to `w`, matching `options`
+func newCompressWriter(w io.Writer, options compressionOptions) (io.WriteCloser, error) {
+	switch options.compression {
+	case CompressionGzip:
+		writer, err := gzip.NewWriterLevel(w, options.level)
+		if err != nil {
+			return nil, err
+		}
+		writer.Name = options.header.Name
+		writer.Comment = options.header.Comment
+		writer.ModTime = options.header.ModTime
+		writer.OS = options.header.OS
+		return writer, nil
+	case CompressionBrotli:
+		return brotli.NewWriterOptions(w, brotli.WriterOptions{
+			Quality: options.level,
+			LGWin:   options.window,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown compression '%s'", options.compression)
+}
+
+// Wraps `write` so its contents are compressed to match `options`
+func compressWrite(options compressionOptions, write func(w io.Writer) error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		writer, err := newCompressWriter(w, options)
+		if err != nil {
+			return err
+		}
+		if err := write(writer); err != nil {
+			return err
+		}
+		return writer.Close()
+	}
+}
+
+// Opens a file, decompressing it if needed
+func openDecompressed(filePath string, compression Compression) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decompress(file, compression)
+}
+
+// Reads the whole (decompressed) contents of a file
+func readDecompressed(filePath string, compression Compression) ([]byte, error) {
+	// Allocates the file size upfront, rather than growing as it is read
+	if compression == CompressionNone {
+		return os.ReadFile(filePath)
+	}
+
+	reader, err := openDecompressed(filePath, compression)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// Wraps an open file, so it is read decompressed. The file is closed if it can not be decompressed.
+func decompress(file *os.File, compression Compression) (io.ReadCloser, error) {
+	if compression == CompressionNone {
+		return file, nil
+	}
+
+	var reader io.Reader
+	var err error
+
+	switch compression {
+	case CompressionGzip:
+		reader, err = gzip.NewReader(file)
+	case CompressionBrotli:
+		reader = brotli.NewReader(file)
+	default:
+		err = fmt.Errorf("unknown compression '%s'", compression)
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &decompressReader{Reader: reader, file: file}, nil
+}
+
+type decompressReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (d *decompressReader) Close() error {
+	return d.file.Close()
+}
+
+// Stages regenerated `.gz` and `.br` siblings (e.g. `main.js.gz`) of a file,
+// compressed from its staged contents, at matching compression settings.
+//
+// `relPath` is relative to `Reactenv.Dir`, and must already be staged.
+func (r *Reactenv) stageCompressedSiblings(tx *Transaction, relPath string) error {
+	sourcePath := filepath.Join(r.Dir, filepath.FromSlash(relPath))
+	outPath := r.outPathRel(relPath)
+
+	for _, c := range compressionExtensions {
+		siblingPath := sourcePath + c.Extension
+
+		if _, err := os.Stat(siblingPath); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		options, err := readCompressionOptions(siblingPath, c.Compression)
+
+		if err != nil {
+			return fmt.Errorf("unable to read '%s': %w", relPath+c.Extension, err)
+		}
+
+		err = r.stagePath(tx, siblingPath, outPath+c.Extension, compressWrite(options, func(w io.Writer) error {
+			contents, err := tx.Open(outPath)
+			if err != nil {
+				return err
+			}
+			defer contents.Close()
+			_, err = io.Copy(w, contents)
+			return err
+		}))
+
+		if err != nil {
+			return fmt.Errorf("unable to regenerate '%s': %w", relPath+c.Extension, err)
+		}
+	}
+
+	return nil
+}
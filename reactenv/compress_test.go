@@ -0,0 +1,223 @@
+package reactenv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Compresses `contents` as gzip, with a header `name` and `modTime`
+func gzipBytes(t *testing.T, contents string, level int, name string, modTime time.Time) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&compressed, level)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer.Name = name
+	writer.ModTime = modTime
+
+	if _, err := io.WriteString(writer, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return compressed.Bytes()
+}
+
+// Compresses `contents` as brotli, with a window size of `window` (log2)
+func brotliBytes(t *testing.T, contents string, quality int, window int) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	writer := brotli.NewWriterOptions(&compressed, brotli.WriterOptions{Quality: quality, LGWin: window})
+
+	if _, err := io.WriteString(writer, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return compressed.Bytes()
+}
+
+// Reads the decompressed contents of a file within `dir`
+func readDecompressedFile(t *testing.T, dir string, relPath string) string {
+	t.Helper()
+
+	compression, _ := CompressionFromPath(relPath)
+	contents, err := readDecompressed(filepath.Join(dir, filepath.FromSlash(relPath)), compression)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(contents)
+}
+
+func TestBrotliWindow(t *testing.T) {
+	contents := bytes.Repeat([]byte("reactenv "), 1000)
+
+	for window := 10; window <= 24; window++ {
+		compressed := brotliBytes(t, string(contents), 11, window)
+
+		if got, err := brotliWindow(compressed[0]); err != nil || got != window {
+			t.Errorf("brotliWindow of a stream with LGWin %d = %d, %v", window, got, err)
+		}
+	}
+
+	// Large window streams (RFC 7932 extension) are not supported
+	if window, err := brotliWindow(0x11); err == nil {
+		t.Errorf("brotliWindow(0x11) = %d, want an error", window)
+	}
+}
+
+func TestReadCompressionOptions(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		contents    []byte
+		compression Compression
+		want        compressionOptions
+	}{
+		{"best.gz", gzipBytes(t, "a", gzip.BestCompression, "best", modTime), CompressionGzip, compressionOptions{level: gzip.BestCompression}},
+		{"fast.gz", gzipBytes(t, "a", gzip.BestSpeed, "fast", modTime), CompressionGzip, compressionOptions{level: gzip.BestSpeed}},
+		{"default.gz", gzipBytes(t, "a", 6, "default", modTime), CompressionGzip, compressionOptions{level: gzip.DefaultCompression}},
+		{"a.br", brotliBytes(t, "a", 5, 12), CompressionBrotli, compressionOptions{level: brotli.BestCompression, window: 12}},
+	}
+
+	for _, test := range tests {
+		filePath := filepath.Join(dir, test.name)
+
+		if err := os.WriteFile(filePath, test.contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		options, err := readCompressionOptions(filePath, test.compression)
+
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if options.level != test.want.level || options.window != test.want.window {
+			t.Errorf("%s: level %d, window %d, want level %d, window %d", test.name, options.level, options.window, test.want.level, test.want.window)
+		}
+
+		if test.compression == CompressionGzip && (options.header.Name != test.name[:len(test.name)-3] || !options.header.ModTime.Equal(modTime)) {
+			t.Errorf("%s: header name %q, modified %v, want the original", test.name, options.header.Name, options.header.ModTime)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "invalid.gz"), []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readCompressionOptions(filepath.Join(dir, "invalid.gz"), CompressionGzip); err == nil {
+		t.Errorf("readCompressionOptions of an invalid gzip file succeeded")
+	}
+}
+
+// Compressed siblings are regenerated from the injected file, at the same settings
+func TestStageCompressedSiblings(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	writeFiles(t, dir, map[string]string{
+		"main.js":     `a="__reactenv.A"`,
+		"main.js.gz":  string(gzipBytes(t, `a="__reactenv.A"`, gzip.BestSpeed, "main.js", modTime)),
+		"main.js.br":  string(brotliBytes(t, `a="__reactenv.A"`, 11, 18)),
+		"other.js":    `b="__reactenv.A"`,
+		"other.js.gz": string(gzipBytes(t, `b="__reactenv.A"`, gzip.BestCompression, "", time.Time{})),
+	})
+
+	inject(t, NewReactenv(), dir, nil, map[string]string{"A": "a"})
+
+	for relPath, want := range map[string]string{"main.js.gz": `a="a"`, "main.js.br": `a="a"`, "other.js.gz": `b="a"`} {
+		if got := readDecompressedFile(t, dir, relPath); got != want {
+			t.Errorf("'%s' decompresses to %q, want %q", relPath, got, want)
+		}
+	}
+
+	gz, err := readCompressionOptions(filepath.Join(dir, "main.js.gz"), CompressionGzip)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gz.level != gzip.BestSpeed || gz.header.Name != "main.js" || !gz.header.ModTime.Equal(modTime) {
+		t.Errorf("'main.js.gz' has level %d, name %q, modified %v, want the original settings", gz.level, gz.header.Name, gz.header.ModTime)
+	}
+
+	if other, err := readCompressionOptions(filepath.Join(dir, "other.js.gz"), CompressionGzip); err != nil || other.level != gzip.BestCompression || other.header.Name != "" {
+		t.Errorf("'other.js.gz' has level %d, name %q (%v), want the original settings", other.level, other.header.Name, err)
+	}
+
+	if br, err := readCompressionOptions(filepath.Join(dir, "main.js.br"), CompressionBrotli); err != nil || br.window != 18 {
+		t.Errorf("'main.js.br' has window %d (%v), want 18", br.window, err)
+	}
+}
+
+// With `Reactenv.Compressed`, precompressed assets with no uncompressed file are injected (and recompressed)
+func TestCompressedOnly(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	writeFiles(t, dir, map[string]string{
+		"only.js.gz": string(gzipBytes(t, `a="__reactenv.A"`, gzip.BestCompression, "only.js", modTime)),
+		"only.js.br": string(brotliBytes(t, `a="__reactenv.A"`, 11, 16)),
+		// Has an uncompressed file, so only that is scanned
+		"main.js":    `b="__reactenv.A"`,
+		"main.js.gz": string(gzipBytes(t, `b="__reactenv.A"`, gzip.BestCompression, "", time.Time{})),
+	})
+
+	renv := NewReactenv()
+	renv.Compressed = true
+	inject(t, renv, dir, nil, map[string]string{"A": "a"})
+
+	paths := make([]string, 0, len(renv.Files))
+	for _, file := range renv.Files {
+		paths = append(paths, file.Path)
+	}
+
+	if len(paths) != 3 {
+		t.Errorf("injected %v, want 'main.js', 'only.js.br' and 'only.js.gz'", paths)
+	}
+
+	for relPath, want := range map[string]string{"only.js.gz": `a="a"`, "only.js.br": `a="a"`, "main.js": `b="a"`, "main.js.gz": `b="a"`} {
+		if got := readDecompressedFile(t, dir, relPath); got != want {
+			t.Errorf("'%s' decompresses to %q, want %q", relPath, got, want)
+		}
+	}
+
+	gz, err := readCompressionOptions(filepath.Join(dir, "only.js.gz"), CompressionGzip)
+
+	if err != nil || gz.level != gzip.BestCompression || gz.header.Name != "only.js" || !gz.header.ModTime.Equal(modTime) {
+		t.Errorf("'only.js.gz' has level %d, name %q, modified %v (%v), want the original settings", gz.level, gz.header.Name, gz.header.ModTime, err)
+	}
+
+	if br, err := readCompressionOptions(filepath.Join(dir, "only.js.br"), CompressionBrotli); err != nil || br.window != 16 {
+		t.Errorf("'only.js.br' has window %d (%v), want 16", br.window, err)
+	}
+
+	// Without `Reactenv.Compressed`, precompressed assets are never scanned
+	renv = NewReactenv()
+	if paths := findFiles(t, renv, dir); len(paths) != 1 || paths[0] != "main.js" {
+		t.Errorf("found %v without Compressed, want only 'main.js'", paths)
+	}
+}
@@ -0,0 +1,225 @@
+package reactenv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Matches a hash source within a Content-Security-Policy, e.g. `sha256-<base64>` (without quotes)
+var cspHashExpression = regexp.MustCompile(`(?:sha256|sha384|sha512)-[A-Za-z0-9+/_-]+={0,2}`)
+
+// `<meta http-equiv>` values of a Content-Security-Policy
+var cspMetaHeaders = []string{"content-security-policy", "content-security-policy-report-only"}
+
+// Inline `<script>` and `<style>` contents of an HTML file, in order
+type inlineContents struct {
+	scripts [][]byte
+	styles  [][]byte
+}
+
+// Returns the contents of every inline `<script>` (without a `src`) and `<style>` element
+func findInlineContents(contents []byte) inlineContents {
+	inline := inlineContents{}
+	for _, tag := range scanHTMLTags(contents) {
+		if tag.contentStart < 0 {
+			continue
+		}
+		if tag.name == "style" {
+			inline.styles = append(inline.styles, contents[tag.contentStart:tag.contentEnd])
+		} else if _, ok := tag.attr("src"); !ok {
+			inline.scripts = append(inline.scripts, contents[tag.contentStart:tag.contentEnd])
+		}
+	}
+	return inline
+}
+
+// Returns the CSP hash source of inline contents (without quotes), e.g. `sha256-<base64>`
+func cspHash(algorithm string, contents []byte) string {
+	digest := integrityAlgorithms[algorithm]()
+	digest.Write(contents)
+	return algorithm + "-" + base64.StdEncoding.EncodeToString(digest.Sum(nil))
+}
+
+// Updates the hashes of inline scripts and styles that changed when their
+// HTML file was injected, in the Content-Security-Policy `<meta http-equiv>`
+// tag of every HTML file in `Dir`. If `Reactenv.CSPHeaderFile` is set, the
+// hashes of every inline script and style are written there (with a warning
+// if no HTML files are matched, so none were injected).
+//
+// Returns the path of every HTML file with an updated policy.
+func (r *Reactenv) stageCSP(tx *Transaction) ([]string, error) {
+	htmlPaths, err := r.findHTMLFiles()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Old hash sources, and what they are replaced with
+	replacements := make(map[string]string)
+
+	for _, htmlPath := range htmlPaths {
+		file, ok := r.staged[htmlPath]
+
+		if !ok {
+			continue
+		}
+
+		original, err := r.fileContents(file)
+
+		if err != nil {
+			return nil, &FileError{Path: htmlPath, Op: "read", Err: err}
+		}
+
+		injected, err := r.readStaged(tx, file)
+
+		if err != nil {
+			return nil, &FileError{Path: htmlPath, Op: "read", Err: err}
+		}
+
+		before, after := findInlineContents(original), findInlineContents(injected)
+
+		if len(before.scripts) != len(after.scripts) || len(before.styles) != len(after.styles) {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("'%s' has a different number of inline scripts or styles after injection, so their Content-Security-Policy hashes were not updated", htmlPath))
+			continue
+		}
+
+		inlineAfter := slices.Concat(after.scripts, after.styles)
+		for i, contents := range slices.Concat(before.scripts, before.styles) {
+			contentsNew := inlineAfter[i]
+			if bytes.Equal(contents, contentsNew) {
+				continue
+			}
+			for algorithm := range integrityAlgorithms {
+				replacements[cspHash(algorithm, contents)] = cspHash(algorithm, contentsNew)
+			}
+		}
+	}
+
+	updated := make([]string, 0)
+
+	for _, htmlPath := range htmlPaths {
+		if len(replacements) == 0 {
+			break
+		}
+
+		ok, err := r.stageProcessed(tx, htmlPath, func(contents []byte) ([]byte, error) {
+			return updateCSPMeta(contents, replacements), nil
+		})
+
+		if err != nil {
+			return nil, &FileError{Path: htmlPath, Op: "update Content-Security-Policy in", Err: err}
+		}
+
+		if ok {
+			updated = append(updated, htmlPath)
+		}
+	}
+
+	if r.CSPHeaderFile != "" {
+		matched := slices.ContainsFunc(htmlPaths, func(htmlPath string) bool {
+			return r.isFileMatch(path.Base(htmlPath))
+		})
+
+		if len(htmlPaths) > 0 && !matched {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("No HTML files are matched by '%s', so any placeholders in inline scripts and styles were not injected before they were hashed (match '.html' files to inject them)", strings.Join(r.FileMatchExpressions(), "', '")))
+		}
+
+		if err := r.stageCSPHeaderFile(tx, htmlPaths); err != nil {
+			return nil, &FileError{Path: r.CSPHeaderFile, Op: "write", Err: err}
+		}
+	}
+
+	return updated, nil
+}
+
+// Returns the contents of an HTML file, with every hash source in `replacements` replaced within Content-Security-Policy `<meta>` tags
+func updateCSPMeta(contents []byte, replacements map[string]string) []byte {
+	contentsNew := make([]byte, 0, len(contents))
+	lastIndex := 0
+
+	for _, tag := range scanHTMLTags(contents) {
+		if tag.name != "meta" {
+			continue
+		}
+
+		header, ok := tag.attr("http-equiv")
+
+		if !ok || !slices.Contains(cspMetaHeaders, strings.ToLower(strings.TrimSpace(header.value))) {
+			continue
+		}
+
+		policy, ok := tag.attr("content")
+
+		if !ok || policy.valueStart < 0 {
+			continue
+		}
+
+		// Hash sources never contain characters escaped in HTML, so they are replaced in the raw attribute
+		policyNew := cspHashExpression.ReplaceAllFunc(contents[policy.valueStart:policy.valueEnd], func(source []byte) []byte {
+			if replacement, ok := replacements[string(source)]; ok {
+				return []byte(replacement)
+			}
+			return source
+		})
+
+		contentsNew = append(contentsNew, contents[lastIndex:policy.valueStart]...)
+		contentsNew = append(contentsNew, policyNew...)
+		lastIndex = policy.valueEnd
+	}
+
+	return append(contentsNew, contents[lastIndex:]...)
+}
+
+// Stages `Reactenv.CSPHeaderFile`, with the `sha256` hash of every inline
+// script and style (in every HTML file in `Dir`, after injection) as CSP
+// directives, e.g. `script-src 'sha256-...'; style-src 'sha256-...'`.
+func (r *Reactenv) stageCSPHeaderFile(tx *Transaction, htmlPaths []string) error {
+	scripts := make([]string, 0)
+	styles := make([]string, 0)
+
+	for _, htmlPath := range htmlPaths {
+		var contents []byte
+		var err error
+
+		if file, ok := r.staged[htmlPath]; ok {
+			contents, err = r.readStaged(tx, file)
+		} else {
+			contents, err = os.ReadFile(filepath.Join(r.Dir, filepath.FromSlash(htmlPath)))
+		}
+
+		if err != nil {
+			return err
+		}
+
+		inline := findInlineContents(contents)
+
+		for _, script := range inline.scripts {
+			if source := "'" + cspHash("sha256", script) + "'"; !slices.Contains(scripts, source) {
+				scripts = append(scripts, source)
+			}
+		}
+
+		for _, style := range inline.styles {
+			if source := "'" + cspHash("sha256", style) + "'"; !slices.Contains(styles, source) {
+				styles = append(styles, source)
+			}
+		}
+	}
+
+	directives := make([]string, 0, 2)
+	if len(scripts) > 0 {
+		directives = append(directives, "script-src "+strings.Join(scripts, " "))
+	}
+	if len(styles) > 0 {
+		directives = append(directives, "style-src "+strings.Join(styles, " "))
+	}
+
+	return tx.StageBytes(r.CSPHeaderFile, []byte(strings.Join(directives, "; ")+"\n"))
+}
@@ -0,0 +1,115 @@
+package reactenv
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdateCSPMeta(t *testing.T) {
+	replacements := map[string]string{"sha256-old=": "sha256-new=", "sha384-old": "sha384-new"}
+
+	tests := []struct {
+		contents string
+		want     string
+	}{
+		{
+			`<meta http-equiv="Content-Security-Policy" content="script-src 'self' 'sha256-old=' 'sha256-other='; style-src 'sha384-old'">`,
+			`<meta http-equiv="Content-Security-Policy" content="script-src 'self' 'sha256-new=' 'sha256-other='; style-src 'sha384-new'">`,
+		},
+		{
+			`<META HTTP-EQUIV=" content-security-policy-report-only " CONTENT='script-src &#39;sha256-old=&#39;'>`,
+			`<META HTTP-EQUIV=" content-security-policy-report-only " CONTENT='script-src &#39;sha256-new=&#39;'>`,
+		},
+		// Only Content-Security-Policy `<meta>` tags are changed
+		{
+			`<meta name="description" content="sha256-old="><meta http-equiv="refresh" content="sha256-old="><p>sha256-old=</p>`,
+			`<meta name="description" content="sha256-old="><meta http-equiv="refresh" content="sha256-old="><p>sha256-old=</p>`,
+		},
+		{
+			`<meta http-equiv="Content-Security-Policy" content>`,
+			`<meta http-equiv="Content-Security-Policy" content>`,
+		},
+	}
+
+	for _, test := range tests {
+		if got := string(updateCSPMeta([]byte(test.contents), replacements)); got != test.want {
+			t.Errorf("updateCSPMeta(%q)\n got: %s\nwant: %s", test.contents, got, test.want)
+		}
+	}
+}
+
+func TestCSP(t *testing.T) {
+	dir := t.TempDir()
+	headerFile := filepath.Join(t.TempDir(), "csp.txt")
+
+	script := `window.env={a:"__reactenv.A"}`
+	style := `a::after{content:"__reactenv.A"}`
+	unrelated := `console.log(1)`
+
+	writeFiles(t, dir, map[string]string{
+		"main.js": `a="__reactenv.A"`,
+		"index.html": `<meta http-equiv="Content-Security-Policy" content="script-src 'self' '` + cspHash("sha256", []byte(script)) + `' '` + cspHash("sha256", []byte(unrelated)) + `'; style-src '` + cspHash("sha384", []byte(style)) + `'">
+<script>` + script + `</script><script src="vendor.js"></script><script>` + unrelated + `</script><style>` + style + `</style>`,
+		// Not injected, but uses the same inline script
+		"other.html": `<meta http-equiv="Content-Security-Policy" content="script-src '` + cspHash("sha512", []byte(script)) + `'">`,
+	})
+
+	renv := NewReactenv()
+	renv.CSPHeaderFile = headerFile
+	inject(t, renv, dir, []string{".js,.html"}, map[string]string{"A": "a"})
+
+	scriptNew := `window.env={a:"a"}`
+	styleNew := `a::after{content:"a"}`
+
+	want := map[string]string{
+		"index.html": `<meta http-equiv="Content-Security-Policy" content="script-src 'self' '` + cspHash("sha256", []byte(scriptNew)) + `' '` + cspHash("sha256", []byte(unrelated)) + `'; style-src '` + cspHash("sha384", []byte(styleNew)) + `'">
+<script>` + scriptNew + `</script><script src="vendor.js"></script><script>` + unrelated + `</script><style>` + styleNew + `</style>`,
+		"other.html": `<meta http-equiv="Content-Security-Policy" content="script-src '` + cspHash("sha512", []byte(scriptNew)) + `'">`,
+	}
+
+	for relPath, contents := range want {
+		if got := readFile(t, dir, relPath); got != contents {
+			t.Errorf("'%s' contains\n%s\nwant\n%s", relPath, got, contents)
+		}
+	}
+
+	if !reflect.DeepEqual(renv.CSPFiles, []string{"index.html", "other.html"}) {
+		t.Errorf("updated %v, want 'index.html' and 'other.html'", renv.CSPFiles)
+	}
+
+	// Every inline script and style (after injection), once each
+	wantHeader := "script-src '" + cspHash("sha256", []byte(scriptNew)) + "' '" + cspHash("sha256", []byte(unrelated)) + "'; style-src '" + cspHash("sha256", []byte(styleNew)) + "'\n"
+
+	if got := readFile(t, filepath.Dir(headerFile), filepath.Base(headerFile)); got != wantHeader {
+		t.Errorf("header file contains %q, want %q", got, wantHeader)
+	}
+
+	if len(renv.Warnings) != 0 {
+		t.Errorf("warnings are %q, want none", renv.Warnings)
+	}
+}
+
+// Hashes in the header file are of HTML files as they are, when none are matched (so injected)
+func TestCSPHeaderFileUnmatched(t *testing.T) {
+	dir := t.TempDir()
+	headerFile := filepath.Join(t.TempDir(), "csp.txt")
+
+	writeFiles(t, dir, map[string]string{
+		"main.js":    `a="__reactenv.A"`,
+		"index.html": `<script>window.env={a:"__reactenv.A"}</script>`,
+	})
+
+	renv := NewReactenv()
+	renv.CSPHeaderFile = headerFile
+	inject(t, renv, dir, nil, map[string]string{"A": "a"})
+
+	if got, want := readFile(t, filepath.Dir(headerFile), filepath.Base(headerFile)), "script-src '"+cspHash("sha256", []byte(`window.env={a:"__reactenv.A"}`))+"'\n"; got != want {
+		t.Errorf("header file contains %q, want %q", got, want)
+	}
+
+	if len(renv.Warnings) != 1 || !strings.Contains(renv.Warnings[0], "No HTML files are matched") {
+		t.Errorf("warnings are %q, want one for the unmatched HTML files", renv.Warnings)
+	}
+}
@@ -0,0 +1,174 @@
+package reactenv
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Single replaced occurrence, with a few bytes of surrounding context
+type DiffHunk = struct {
+	Occurrence Occurrence
+	// Line number of the occurrence (starts at 1)
+	Line int
+	// Byte column of the occurrence within `Line` (starts at 1)
+	Column int
+	// Original contents, including context
+	Before string
+	// Replaced contents, including context (empty when `Err` is set)
+	After string
+	// Value could not be rendered (e.g. it is not set)
+	Err error
+}
+
+// Returns a hunk for each occurrence, showing the contents before and after it is replaced.
+//
+// Bundles are usually minified into a single line, so context is limited
+// to `contextBytes` either side of the occurrence (and never crosses a line break).
+func (r *Reactenv) DiffOccurrences(file *File, fileContents []byte, occurrences []Occurrence, contextBytes int) []DiffHunk {
+	hunks := make([]DiffHunk, 0, len(occurrences))
+
+	line := 1
+	lineStart := 0
+	lastIndex := 0
+
+	for _, occurrence := range occurrences {
+		start, end := OccurrenceSpan(occurrence)
+
+		if start > lastIndex {
+			segment := fileContents[lastIndex:start]
+			if lines := bytes.Count(segment, []byte{'\n'}); lines > 0 {
+				line += lines
+				lineStart = lastIndex + bytes.LastIndexByte(segment, '\n') + 1
+			}
+			lastIndex = start
+		}
+
+		contextStart := max(start-contextBytes, lineStart)
+		contextEnd := min(end+contextBytes, len(fileContents))
+		if i := bytes.IndexByte(fileContents[end:contextEnd], '\n'); i >= 0 {
+			contextEnd = end + i
+		}
+
+		before := string(fileContents[contextStart:contextEnd])
+		after := ""
+		value, err := r.OccurrenceValue(file, occurrence)
+
+		if err == nil {
+			after = string(fileContents[contextStart:start]) + value + string(fileContents[end:contextEnd])
+		}
+
+		hunks = append(hunks, DiffHunk{
+			Occurrence: occurrence,
+			Line:       line,
+			Column:     start - lineStart + 1,
+			Before:     before,
+			After:      after,
+			Err:        err,
+		})
+	}
+
+	return hunks
+}
+
+// Streaming version of `DiffOccurrences`, for files too large to read into
+// memory (see `Reactenv.StreamThreshold`). Only the context of the current line is kept.
+func (r *Reactenv) DiffOccurrencesReader(file *File, reader io.Reader, occurrences []Occurrence, contextBytes int) ([]DiffHunk, error) {
+	hunks := make([]DiffHunk, 0, len(occurrences))
+
+	// Large enough to peek at a whole placeholder (see `REACTENV_STREAM_BUFFER_MAX`), and the context after it
+	buffered := bufio.NewReaderSize(reader, REACTENV_STREAM_BUFFER_MAX+contextBytes)
+
+	line := 1
+	lineStart := 0
+	offset := 0
+	// Bytes of the current line before `offset` (up to `contextBytes` of them)
+	lineEnd := make([]byte, 0, 2*contextBytes)
+
+	for _, occurrence := range occurrences {
+		start, end := OccurrenceSpan(occurrence)
+
+		for offset < start {
+			segment, err := buffered.Peek(min(start-offset, buffered.Size()))
+
+			if err != nil {
+				return nil, changedWhileStreaming(err)
+			}
+
+			if i := bytes.LastIndexByte(segment, '\n'); i >= 0 {
+				line += bytes.Count(segment, []byte{'\n'})
+				lineStart = offset + i + 1
+				lineEnd = lineEnd[:0]
+			}
+
+			lineEnd = append(lineEnd, segment[max(len(segment)-contextBytes, lineStart-offset, 0):]...)
+			if len(lineEnd) > contextBytes {
+				lineEnd = lineEnd[:copy(lineEnd, lineEnd[len(lineEnd)-contextBytes:])]
+			}
+
+			buffered.Discard(len(segment))
+			offset += len(segment)
+		}
+
+		// The placeholder, and context after it (up to a line break)
+		placeholder, err := buffered.Peek(min(end-start+contextBytes, buffered.Size()))
+
+		if len(placeholder) < end-start {
+			return nil, changedWhileStreaming(err)
+		}
+
+		after := placeholder[end-start:]
+		if i := bytes.IndexByte(after, '\n'); i >= 0 {
+			after = after[:i]
+		}
+
+		hunk := DiffHunk{
+			Occurrence: occurrence,
+			Line:       line,
+			Column:     start - lineStart + 1,
+			Before:     string(lineEnd) + string(placeholder[:end-start]) + string(after),
+		}
+
+		value, err := r.OccurrenceValue(file, occurrence)
+
+		if err == nil {
+			hunk.After = string(lineEnd) + value + string(after)
+		}
+
+		hunk.Err = err
+		hunks = append(hunks, hunk)
+	}
+
+	return hunks, nil
+}
+
+// Returns a hunk for each occurrence in a file (see `DiffOccurrences`).
+//
+// Streamed files are read again (see `DiffOccurrencesReader`), rather than into memory.
+func (r *Reactenv) DiffFile(file *File, occurrences []Occurrence, contextBytes int) ([]DiffHunk, error) {
+	if !file.stream {
+		fileContents, err := r.fileContents(file)
+
+		if err != nil {
+			return nil, &FileError{Path: file.Path, Op: "read", Err: err}
+		}
+
+		return r.DiffOccurrences(file, fileContents, occurrences, contextBytes), nil
+	}
+
+	reader, err := r.OpenFile(file)
+
+	if err != nil {
+		return nil, &FileError{Path: file.Path, Op: "read", Err: err}
+	}
+
+	defer reader.Close()
+
+	hunks, err := r.DiffOccurrencesReader(file, reader, occurrences, contextBytes)
+
+	if err != nil {
+		return nil, &FileError{Path: file.Path, Op: "read", Err: err}
+	}
+
+	return hunks, nil
+}
@@ -0,0 +1,46 @@
+package reactenv
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// Streamed diffs must match the diff of the whole file
+func TestDiffOccurrencesReader(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	renv := NewReactenv()
+	renv.OccurrenceKeysReplacement = map[string]string{"A": "a\"b", "B_1": "true"}
+
+	for i := 0; i < 300; i++ {
+		contents := generateContents(random, random.Intn(200))
+		file := &File{Path: "file.js", Syntax: SyntaxJS}
+		occurrences := ScanOccurrences(contents, file.Syntax)
+
+		for _, contextBytes := range []int{0, 1, 8, 40} {
+			want := renv.DiffOccurrences(file, contents, occurrences, contextBytes)
+			got, err := renv.DiffOccurrencesReader(file, iotest.OneByteReader(bytes.NewReader(contents)), occurrences, contextBytes)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("DiffOccurrencesReader(%q, %d)\n got: %+v\nwant: %+v", contents, contextBytes, got, want)
+			}
+		}
+	}
+}
+
+func TestDiffOccurrencesReaderChanged(t *testing.T) {
+	renv := NewReactenv()
+	contents := []byte(`a="__reactenv.A"`)
+	occurrences := ScanOccurrences(contents, SyntaxJS)
+
+	if _, err := renv.DiffOccurrencesReader(&File{Path: "file.js", Syntax: SyntaxJS}, bytes.NewReader(contents[:10]), occurrences, 8); err == nil {
+		t.Errorf("DiffOccurrencesReader of a truncated file succeeded")
+	}
+}
@@ -0,0 +1,341 @@
+package reactenv
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Error when parsing a `.env` file, includes the line number
+type EnvFileError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *EnvFileError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// Parses a `.env` file, and adds every variable to `values`.
+//
+// See `ParseEnv`.
+func ParseEnvFile(filePath string, values map[string]string, lookup func(string) (string, bool)) error {
+	contents, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return err
+	}
+
+	return ParseEnv(filePath, string(contents), values, lookup)
+}
+
+// Parses the contents of a `.env` file, and adds every variable to `values`
+// (overriding any existing values).
+//
+// Supports:
+//   - Comments (`# ...`), and blank lines
+//   - `export` prefixes (`export KEY=value`)
+//   - Unquoted values (trailing ` # comments` are removed)
+//   - Double-quoted values, with escapes (`\n`, `\t`, `\"`, ...)
+//   - Single-quoted (and backtick-quoted) values, which are used as-is
+//   - Multi-line quoted values
+//   - `${VAR}`, `${VAR:-default}`, `${VAR-default}` and `$VAR` expansion,
+//     in unquoted and double-quoted values (escape with `\$`)
+//
+// Variables are expanded using `lookup` first (when not `nil`), then `values`.
+func ParseEnv(name string, contents string, values map[string]string, lookup func(string) (string, bool)) error {
+	p := &envParser{
+		name:     name,
+		contents: strings.ReplaceAll(contents, "\r\n", "\n"),
+		line:     1,
+		values:   values,
+		lookup:   lookup,
+	}
+
+	for {
+		p.skipBlankLines()
+
+		if p.eof() {
+			return nil
+		}
+
+		if err := p.parseEntry(); err != nil {
+			return err
+		}
+	}
+}
+
+type envParser struct {
+	name     string
+	contents string
+	pos      int
+	line     int
+	values   map[string]string
+	lookup   func(string) (string, bool)
+}
+
+func (p *envParser) eof() bool {
+	return p.pos >= len(p.contents)
+}
+
+func (p *envParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.contents[p.pos]
+}
+
+func (p *envParser) errorf(line int, format string, args ...interface{}) error {
+	return &EnvFileError{
+		File:    p.name,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *envParser) skipInlineSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// Skips to the start of the next line
+func (p *envParser) skipLine() {
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+	if !p.eof() {
+		p.pos++
+		p.line++
+	}
+}
+
+// Skips blank lines, and comments
+func (p *envParser) skipBlankLines() {
+	for !p.eof() {
+		p.skipInlineSpace()
+
+		switch p.peek() {
+		case '\n', '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+func isEnvNameByte(b byte, first bool) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (!first && ((b >= '0' && b <= '9') || b == '.'))
+}
+
+func (p *envParser) readName() string {
+	start := p.pos
+	for !p.eof() && isEnvNameByte(p.peek(), p.pos == start) {
+		p.pos++
+	}
+	return p.contents[start:p.pos]
+}
+
+func (p *envParser) parseEntry() error {
+	line := p.line
+
+	if strings.HasPrefix(p.contents[p.pos:], "export") {
+		next := p.pos + len("export")
+		if next < len(p.contents) && (p.contents[next] == ' ' || p.contents[next] == '\t') {
+			p.pos = next
+			p.skipInlineSpace()
+		}
+	}
+
+	key := p.readName()
+
+	if key == "" {
+		return p.errorf(line, "expected a variable name, found '%c'", p.peek())
+	}
+
+	p.skipInlineSpace()
+
+	if p.peek() != '=' {
+		return p.errorf(line, "expected '=' after '%s'", key)
+	}
+
+	p.pos++
+	p.skipInlineSpace()
+
+	value, err := p.readValue()
+
+	if err != nil {
+		return err
+	}
+
+	p.values[key] = value
+
+	return nil
+}
+
+func (p *envParser) readValue() (string, error) {
+	line := p.line
+	quote := p.peek()
+
+	if quote != '"' && quote != '\'' && quote != '`' {
+		return p.readUnquotedValue()
+	}
+
+	p.pos++
+	start := p.pos
+	escaped := false
+
+	for ; !p.eof(); p.pos++ {
+		c := p.peek()
+
+		if c == '\n' {
+			p.line++
+		}
+
+		if escaped {
+			escaped = false
+			continue
+		}
+
+		if c == '\\' && quote == '"' {
+			escaped = true
+			continue
+		}
+
+		if c == quote {
+			break
+		}
+	}
+
+	if p.eof() {
+		return "", p.errorf(line, "unterminated quoted value (missing closing %c)", quote)
+	}
+
+	raw := p.contents[start:p.pos]
+	p.pos++
+
+	// Only whitespace, or a comment, may follow a quoted value
+	p.skipInlineSpace()
+	if !p.eof() && p.peek() != '\n' && p.peek() != '#' {
+		return "", p.errorf(p.line, "unexpected '%c' after quoted value", p.peek())
+	}
+	p.skipLine()
+
+	if quote != '"' {
+		return raw, nil
+	}
+
+	return p.expand(raw, true, line)
+}
+
+func (p *envParser) readUnquotedValue() (string, error) {
+	line := p.line
+	start := p.pos
+
+	for !p.eof() && p.peek() != '\n' {
+		// Comments must be preceded by whitespace
+		if p.peek() == '#' && p.pos > start && (p.contents[p.pos-1] == ' ' || p.contents[p.pos-1] == '\t') {
+			break
+		}
+		p.pos++
+	}
+
+	raw := strings.TrimSpace(p.contents[start:p.pos])
+	p.skipLine()
+
+	return p.expand(raw, false, line)
+}
+
+func (p *envParser) lookupValue(name string) (string, bool) {
+	if p.lookup != nil {
+		if value, ok := p.lookup(name); ok {
+			return value, true
+		}
+	}
+	value, ok := p.values[name]
+	return value, ok
+}
+
+// Expands variables in `raw` (and escapes, if `doubleQuoted`)
+func (p *envParser) expand(raw string, doubleQuoted bool, line int) (string, error) {
+	var out strings.Builder
+
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+
+		if c == '\\' && i+1 < len(raw) {
+			next := raw[i+1]
+
+			if doubleQuoted {
+				i++
+				switch next {
+				case 'n':
+					out.WriteByte('\n')
+				case 'r':
+					out.WriteByte('\r')
+				case 't':
+					out.WriteByte('\t')
+				case '"', '\\', '$':
+					out.WriteByte(next)
+				default:
+					out.WriteByte('\\')
+					out.WriteByte(next)
+				}
+				continue
+			}
+
+			if next == '$' {
+				out.WriteByte('$')
+				i++
+				continue
+			}
+		}
+
+		if c == '$' && i+1 < len(raw) && raw[i+1] == '{' {
+			end := strings.IndexByte(raw[i+2:], '}')
+
+			if end < 0 {
+				return "", p.errorf(line, "unterminated '${' expansion")
+			}
+
+			expression := raw[i+2 : i+2+end]
+			name, fallback, hasFallback, emptyFallback := expression, "", false, false
+
+			if index := strings.Index(expression, ":-"); index >= 0 {
+				name, fallback, hasFallback, emptyFallback = expression[:index], expression[index+2:], true, true
+			} else if index := strings.IndexByte(expression, '-'); index >= 0 {
+				name, fallback, hasFallback = expression[:index], expression[index+1:], true
+			}
+
+			if name == "" || !isEnvNameByte(name[0], true) || strings.IndexFunc(name, func(r rune) bool { return r > 0x7f || !isEnvNameByte(byte(r), false) }) >= 0 {
+				return "", p.errorf(line, "invalid variable name in '${%s}'", expression)
+			}
+
+			value, ok := p.lookupValue(name)
+			if hasFallback && (!ok || (emptyFallback && value == "")) {
+				value = fallback
+			}
+
+			out.WriteString(value)
+			i += 2 + end
+			continue
+		}
+
+		if c == '$' && i+1 < len(raw) && isEnvNameByte(raw[i+1], true) {
+			end := i + 1
+			for end < len(raw) && isEnvNameByte(raw[end], end == i+1) && raw[end] != '.' {
+				end++
+			}
+
+			value, _ := p.lookupValue(raw[i+1 : end])
+			out.WriteString(value)
+			i = end - 1
+			continue
+		}
+
+		out.WriteByte(c)
+	}
+
+	return out.String(), nil
+}
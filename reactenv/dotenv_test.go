@@ -0,0 +1,111 @@
+package reactenv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	lookup := func(key string) (string, bool) {
+		value, ok := map[string]string{"HOST": "example.com", "EMPTY": ""}[key]
+		return value, ok
+	}
+
+	tests := []struct {
+		name     string
+		contents string
+		want     map[string]string
+	}{
+		{"empty", "", map[string]string{}},
+		{"comments and blank lines", "# comment\n\n  # indented\nA=1\n", map[string]string{"A": "1"}},
+		{"crlf", "A=1\r\nB=2\r\n", map[string]string{"A": "1", "B": "2"}},
+		{"spaces", "  A = 1  \n", map[string]string{"A": "1"}},
+		{"empty value", "A=\nB=''\n", map[string]string{"A": "", "B": ""}},
+		{"later value wins", "A=1\nA=2\n", map[string]string{"A": "2"}},
+		{"name characters", "a.b_C1=1\n", map[string]string{"a.b_C1": "1"}},
+
+		// `export`
+		{"export", "export A=1\nexport\tB=2\n", map[string]string{"A": "1", "B": "2"}},
+		{"export as a name", "export=1\nexported=2\n", map[string]string{"export": "1", "exported": "2"}},
+
+		// Unquoted values
+		{"unquoted comment", "A=1 # comment\n", map[string]string{"A": "1"}},
+		{"unquoted hash", "A=a#b\n", map[string]string{"A": "a#b"}},
+		{"unquoted escapes are kept", `A=a\nb`, map[string]string{"A": `a\nb`}},
+
+		// Quoting
+		{"double quoted", `A="a b # c"`, map[string]string{"A": "a b # c"}},
+		{"double quoted escapes", `A="a\n\t\"\\\$b\x"`, map[string]string{"A": "a\n\t\"\\$b\\x"}},
+		{"double quoted comment", `A="a" # comment`, map[string]string{"A": "a"}},
+		{"single quoted", `A='a\n$HOST "b"'`, map[string]string{"A": `a\n$HOST "b"`}},
+		{"backtick quoted", "A=`a 'b' \"c\"`", map[string]string{"A": `a 'b' "c"`}},
+
+		// Multi-line values
+		{"multi-line double quoted", "A=\"a\nb\"\nB=2", map[string]string{"A": "a\nb", "B": "2"}},
+		{"multi-line single quoted", "A='a\n\nb'\nB=2", map[string]string{"A": "a\n\nb", "B": "2"}},
+
+		// Expansion
+		{"braces", "A=${HOST}/api", map[string]string{"A": "example.com/api"}},
+		{"bare", "A=https://$HOST/api", map[string]string{"A": "https://example.com/api"}},
+		{"bare stops at dot", "A=$HOST.x", map[string]string{"A": "example.com.x"}},
+		{"double quoted", `A="${HOST}"`, map[string]string{"A": "example.com"}},
+		{"single quoted is not expanded", `A='${HOST}'`, map[string]string{"A": "${HOST}"}},
+		{"escaped", `A=\${HOST}`, map[string]string{"A": "${HOST}"}},
+		{"earlier value", "A=1\nB=${A}2", map[string]string{"A": "1", "B": "12"}},
+		{"lookup before values", "HOST=other\nA=${HOST}", map[string]string{"HOST": "other", "A": "example.com"}},
+		{"unset", "A=[${UNSET}]", map[string]string{"A": "[]"}},
+		{"default when unset", "A=${UNSET:-x}", map[string]string{"A": "x"}},
+		{"default when empty", "A=${EMPTY:-x}", map[string]string{"A": "x"}},
+		{"default when set", "A=${HOST:-x}", map[string]string{"A": "example.com"}},
+		{"dash default when unset", "A=${UNSET-x}", map[string]string{"A": "x"}},
+		{"dash default when empty", "A=${EMPTY-x}", map[string]string{"A": ""}},
+		{"empty default", "A=${UNSET:-}", map[string]string{"A": ""}},
+	}
+
+	for _, test := range tests {
+		values := make(map[string]string)
+
+		if err := ParseEnv(".env", test.contents, values, lookup); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(values, test.want) {
+			t.Errorf("%s: ParseEnv(%q) = %v, want %v", test.name, test.contents, values, test.want)
+		}
+	}
+}
+
+func TestParseEnvErrors(t *testing.T) {
+	tests := []struct {
+		contents string
+		line     int
+	}{
+		{"=1", 1},
+		{"A=1\n\n1A=2", 3},
+		{"A=1\nB", 2},
+		{"A=1\nB 1", 2},
+		{"# comment\nA=\"a\nb", 2},
+		{"A='a", 1},
+		{"A=\"a\nb\" c", 2},
+		{"A=1\nB=${A", 2},
+		{"A=1\nB=${1A}", 2},
+		{"A=1\nB=${}", 2},
+		{"A=\"a\nb\"\nC=${D", 3},
+	}
+
+	for _, test := range tests {
+		err := ParseEnv(".env", test.contents, make(map[string]string), nil)
+
+		var envFileError *EnvFileError
+		if !errors.As(err, &envFileError) {
+			t.Errorf("ParseEnv(%q) = %v, want an *EnvFileError", test.contents, err)
+			continue
+		}
+
+		if envFileError.File != ".env" || envFileError.Line != test.line {
+			t.Errorf("ParseEnv(%q) error at %s:%d, want .env:%d (%v)", test.contents, envFileError.File, envFileError.Line, test.line, err)
+		}
+	}
+}
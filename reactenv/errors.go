@@ -0,0 +1,52 @@
+package reactenv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returned when required environment variables have no value (see `Reactenv.MissingKeys`)
+type MissingKeysError struct {
+	Keys []string
+}
+
+func (e *MissingKeysError) Error() string {
+	return fmt.Sprintf("environment %s not set: '%s'", pluralize("variable", len(e.Keys)), strings.Join(e.Keys, "', '"))
+}
+
+// Returned when occurrences can not be injected (see `Reactenv.OccurrenceErrors`)
+type InvalidOccurrencesError struct {
+	Occurrences []*OccurrenceError
+}
+
+func (e *InvalidOccurrencesError) Error() string {
+	lines := make([]string, 0, len(e.Occurrences))
+	for _, occurrenceError := range e.Occurrences {
+		lines = append(lines, fmt.Sprintf("%s in %s (at byte %d): %v", occurrenceError.Occurrence.Key, occurrenceError.File.Path, occurrenceError.Occurrence.StartEnd[0], occurrenceError.Err))
+	}
+	return fmt.Sprintf("unable to inject %d environment %s: %s", len(e.Occurrences), pluralize("variable", len(e.Occurrences)), strings.Join(lines, "; "))
+}
+
+// Returned when a file can not be read, injected or written
+type FileError struct {
+	// Path of the file, relative to `Reactenv.Dir`
+	Path string
+	// What was being done, e.g. "read"
+	Op  string
+	Err error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("unable to %s '%s': %v", e.Op, e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+func pluralize(word string, count int) string {
+	if count == 1 {
+		return word
+	}
+	return word + "s"
+}
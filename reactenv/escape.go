@@ -0,0 +1,101 @@
+package reactenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// Escapes CSS strings (and `<`, so the value can not close an inline `<style>`)
+var cssEscaper = strings.NewReplacer(`\`, `\5C `, `"`, `\22 `, `'`, `\27 `, "<", `\3C `, "\n", `\A `, "\r", `\D `)
+
+// Escapes `value` so it can be safely injected into a file of `syntax`, within `literal`.
+//
+// Values outside of a literal (in JS or JSON) are injected as a double-quoted string.
+// HTML values are escaped as text (which is also safe within quoted attributes).
+func EscapeValue(value string, syntax Syntax, literal Literal) string {
+	switch syntax {
+	case SyntaxJS:
+		switch literal {
+		case LiteralDoubleQuote:
+			return escapeJsString(value, '"')
+		case LiteralSingleQuote:
+			return escapeJsString(value, '\'')
+		case LiteralTemplate:
+			return escapeJsString(value, '`')
+		default:
+			return `"` + escapeJsString(value, '"') + `"`
+		}
+	case SyntaxJSON:
+		if literal == LiteralDoubleQuote {
+			return escapeJsonString(value)
+		}
+		return `"` + escapeJsonString(value) + `"`
+	case SyntaxHTML:
+		return html.EscapeString(value)
+	case SyntaxCSS:
+		return cssEscaper.Replace(value)
+	}
+
+	return value
+}
+
+// Escapes a JS string, enclosed by `quote`.
+//
+// As well as the quote and backslashes, this escapes line terminators,
+// control characters, `${` (in templates) and `</` or `<!--` (so the
+// value can not close an inline `<script>`).
+func escapeJsString(value string, quote rune) string {
+	var out strings.Builder
+	out.Grow(len(value))
+
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+
+		if r == utf8.RuneError && size == 1 {
+			out.WriteByte(value[i])
+			i += size
+			continue
+		}
+
+		switch {
+		case r == quote, r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r == '$' && quote == '`':
+			out.WriteString(`\$`)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r == '\u2028', r == '\u2029':
+			fmt.Fprintf(&out, `\u%04x`, r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&out, `\x%02x`, r)
+		case r == '<' && (strings.HasPrefix(value[i:], "</") || strings.HasPrefix(value[i:], "<!--")):
+			out.WriteString(`\x3C`)
+		default:
+			out.WriteRune(r)
+		}
+
+		i += size
+	}
+
+	return out.String()
+}
+
+// Escapes a JSON string (without the enclosing quotes)
+func escapeJsonString(value string) string {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(true)
+	encoder.Encode(value)
+
+	escaped := bytes.TrimSpace(out.Bytes())
+	return string(escaped[1 : len(escaped)-1])
+}
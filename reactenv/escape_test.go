@@ -0,0 +1,78 @@
+package reactenv
+
+import (
+	"testing"
+)
+
+func TestEscapeValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		syntax  Syntax
+		literal Literal
+		want    string
+	}{
+		{`a"b`, SyntaxJS, LiteralDoubleQuote, `a\"b`},
+		{`a'b`, SyntaxJS, LiteralDoubleQuote, `a'b`},
+		{`a'b`, SyntaxJS, LiteralSingleQuote, `a\'b`},
+		{"a`${b}", SyntaxJS, LiteralTemplate, "a\\`\\${b}"},
+		{`a\b`, SyntaxJS, LiteralDoubleQuote, `a\\b`},
+		{"a\nb\r\tc", SyntaxJS, LiteralDoubleQuote, `a\nb\r\tc`},
+		{"a\u2028b\x00", SyntaxJS, LiteralDoubleQuote, `a\u2028b\x00`},
+		{"</script><!--", SyntaxJS, LiteralDoubleQuote, `\x3C/script>\x3C!--`},
+		{`a"b`, SyntaxJS, LiteralNone, `"a\"b"`},
+		{`a"b</script>`, SyntaxJSON, LiteralDoubleQuote, `a\"b\u003c/script\u003e`},
+		{`a"b`, SyntaxJSON, LiteralNone, `"a\"b"`},
+		{`a"b</script><img>&`, SyntaxHTML, LiteralNone, `a&#34;b&lt;/script&gt;&lt;img&gt;&amp;`},
+		{`a"b'</style>\`, SyntaxCSS, LiteralNone, `a\22 b\27 \3C /style>\5C `},
+		{`a"b</script>`, SyntaxRaw, LiteralNone, `a"b</script>`},
+	}
+
+	for _, test := range tests {
+		if got := EscapeValue(test.value, test.syntax, test.literal); got != test.want {
+			t.Errorf("EscapeValue(%q, %v, %v) = %q, want %q", test.value, test.syntax, test.literal, got, test.want)
+		}
+	}
+}
+
+// Returns `contents` of a file of `syntax`, with every placeholder injected from `values`
+func render(t *testing.T, contents string, syntax Syntax, values map[string]string) string {
+	t.Helper()
+
+	renv := NewReactenv()
+	renv.OccurrenceKeysReplacement = values
+	file := &File{Path: "file", Syntax: syntax}
+
+	rendered, err := renv.RenderContents(file, []byte(contents), ScanOccurrences([]byte(contents), syntax))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(rendered)
+}
+
+func TestRenderHTML(t *testing.T) {
+	values := map[string]string{"A": `a"b</script><img>`, "F": "true"}
+
+	tests := []struct {
+		contents string
+		want     string
+	}{
+		{`<title>__reactenv.A</title>`, `<title>a&#34;b&lt;/script&gt;&lt;img&gt;</title>`},
+		{`<meta content="__reactenv.A">`, `<meta content="a&#34;b&lt;/script&gt;&lt;img&gt;">`},
+		{`<script>a="__reactenv.A"</script>`, `<script>a="a\"b\x3C/script><img>"</script>`},
+		{`<script>a='__reactenv.A'</script>`, `<script>a='a"b\x3C/script><img>'</script>`},
+		{`<script>a=__reactenv.A</script>`, `<script>a="a\"b\x3C/script><img>"</script>`},
+		{`<SCRIPT defer>a="__reactenv.F:bool"</SCRIPT>`, `<SCRIPT defer>a=true</SCRIPT>`},
+		{`<script type="application/json">{"a":"__reactenv.A"}</script>`, `<script type="application/json">{"a":"a\"b\u003c/script\u003e\u003cimg\u003e"}</script>`},
+		{`<style>a::after{content:"__reactenv.A"}</style>`, `<style>a::after{content:"a\22 b\3C /script>\3C img>"}</style>`},
+		{`<script>a="__reactenv.A"</script><p>__reactenv.A</p>`, `<script>a="a\"b\x3C/script><img>"</script><p>a&#34;b&lt;/script&gt;&lt;img&gt;</p>`},
+		{`<!-- <script>"__reactenv.A"</script> --><b>__reactenv.F:bool</b>`, `<!-- <script>"a&#34;b&lt;/script&gt;&lt;img&gt;"</script> --><b>true</b>`},
+	}
+
+	for _, test := range tests {
+		if got := render(t, test.contents, SyntaxHTML, values); got != test.want {
+			t.Errorf("render(%q)\n got: %s\nwant: %s", test.contents, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package reactenv
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"strings"
+)
+
+// Single rule from a `.reactenvignore` file (or an `--exclude` flag)
+type IgnoreRule = struct {
+	Pattern string
+	// Rule starts with `!`, and re-includes a previously ignored path
+	Negate bool
+	// Rule ends with `/`, and only matches directories
+	DirOnly bool
+}
+
+// Reports whether `name` (a slash-separated path, relative to `Reactenv.Dir`) matches `pattern`.
+//
+// Patterns follow `.gitignore` conventions:
+//   - `*`, `?` and `[...]` match within a single path segment
+//   - `**` matches any number of path segments
+//   - A pattern without a `/` matches the base name at any depth
+//   - A leading `/` anchors the pattern to the root directory
+func MatchGlob(pattern string, name string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	if strings.HasPrefix(pattern, "/") {
+		pattern = pattern[1:]
+	} else if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchGlobSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// Returns an error if `pattern` is not a valid glob
+func ValidateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parses a single ignore rule, using `.gitignore` syntax
+func ParseIgnoreRule(line string) (IgnoreRule, bool) {
+	line = strings.TrimSpace(line)
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return IgnoreRule{}, false
+	}
+
+	rule := IgnoreRule{}
+
+	if strings.HasPrefix(line, "!") {
+		rule.Negate = true
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.DirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	rule.Pattern = line
+
+	return rule, line != ""
+}
+
+// Reads all rules from an ignore file. A missing file is not an error.
+func ReadIgnoreFile(filePath string) ([]IgnoreRule, error) {
+	rules := make([]IgnoreRule, 0)
+
+	file, err := os.Open(filePath)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return rules, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := ParseIgnoreRule(scanner.Text()); ok {
+			if err := ValidateGlob(rule.Pattern); err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// Reports whether `name` is ignored by `rules`. The last matching rule wins.
+func MatchIgnoreRules(rules []IgnoreRule, name string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range rules {
+		if rule.DirOnly && !isDir {
+			continue
+		}
+		if MatchGlob(rule.Pattern, name) {
+			ignored = !rule.Negate
+		}
+	}
+
+	return ignored
+}
@@ -0,0 +1,201 @@
+package reactenv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		// No `/`, matches the base name at any depth
+		{"*.map", "main.js.map", true},
+		{"*.map", "static/js/main.js.map", true},
+		{"*.map", "main.js", false},
+		{"vendor", "vendor", true},
+		{"vendor", "static/vendor", true},
+		{"main.?s", "static/main.js", true},
+		{"main.[jt]s", "main.ts", true},
+		{"main.[jt]s", "main.cs", false},
+
+		// `*` does not match across segments
+		{"static/*.js", "static/main.js", true},
+		{"static/*.js", "static/js/main.js", false},
+
+		// `**` matches any number of segments
+		{"static/**/*.js", "static/main.js", true},
+		{"static/**/*.js", "static/js/chunks/main.js", true},
+		{"static/**/*.js", "public/main.js", false},
+		{"static/**", "static/js/main.js", true},
+		{"**/chunks/*.js", "chunks/main.js", true},
+		{"**/chunks/*.js", "static/js/chunks/main.js", true},
+
+		// Leading `/` anchors to the root directory
+		{"/main.js", "main.js", true},
+		{"/main.js", "static/main.js", false},
+		{"/static", "static", true},
+
+		// Trailing `/` is ignored (see `IgnoreRule.DirOnly`)
+		{"static/", "static", true},
+		{"static/", "public/static", true},
+	}
+
+	for _, test := range tests {
+		if got := MatchGlob(test.pattern, test.name); got != test.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", test.pattern, test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseIgnoreRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want IgnoreRule
+		ok   bool
+	}{
+		{"", IgnoreRule{}, false},
+		{"   ", IgnoreRule{}, false},
+		{"# comment", IgnoreRule{}, false},
+		{"*.map", IgnoreRule{Pattern: "*.map"}, true},
+		{"  *.map  ", IgnoreRule{Pattern: "*.map"}, true},
+		{"!main.js", IgnoreRule{Pattern: "main.js", Negate: true}, true},
+		{"vendor/", IgnoreRule{Pattern: "vendor", DirOnly: true}, true},
+		{"!vendor/", IgnoreRule{Pattern: "vendor", Negate: true, DirOnly: true}, true},
+		{"!", IgnoreRule{Negate: true}, false},
+	}
+
+	for _, test := range tests {
+		got, ok := ParseIgnoreRule(test.line)
+		if ok != test.ok || (ok && got != test.want) {
+			t.Errorf("ParseIgnoreRule(%q) = %+v, %v, want %+v, %v", test.line, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestMatchIgnoreRules(t *testing.T) {
+	rules := make([]IgnoreRule, 0)
+	for _, line := range []string{"*.js", "!main.js", "vendor/", "/static/legacy.js", "!/static/legacy.js", "/static/old.js"} {
+		rule, _ := ParseIgnoreRule(line)
+		rules = append(rules, rule)
+	}
+
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{"chunk.js", false, true},
+		{"static/chunk.js", false, true},
+		// Negated by a later rule
+		{"main.js", false, false},
+		{"static/main.js", false, false},
+		// Last matching rule wins
+		{"static/legacy.js", false, false},
+		{"static/old.js", false, true},
+		// `vendor/` only matches directories
+		{"vendor", true, true},
+		{"static/vendor", true, true},
+		{"vendor", false, false},
+		{"index.html", false, false},
+	}
+
+	for _, test := range tests {
+		if got := MatchIgnoreRules(rules, test.name, test.isDir); got != test.want {
+			t.Errorf("MatchIgnoreRules(%q, isDir %v) = %v, want %v", test.name, test.isDir, got, test.want)
+		}
+	}
+
+	if MatchIgnoreRules(nil, "main.js", false) {
+		t.Errorf("MatchIgnoreRules with no rules ignored 'main.js'")
+	}
+}
+
+// Writes each file (slash-separated path, relative to `dir`)
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for relPath, contents := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Returns the paths of every file found in `dir`, sorted
+func findFiles(t *testing.T, renv *Reactenv, dir string) []string {
+	t.Helper()
+	if err := renv.FindFiles(context.Background(), dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, 0, len(renv.Files))
+	for _, file := range renv.Files {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestFindFilesMaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.js":                  "",
+		"static/main.js":           "",
+		"static/js/main.js":        "",
+		"static/js/chunks/main.js": "",
+	})
+
+	tests := []struct {
+		maxDepth int
+		want     []string
+	}{
+		{0, []string{"main.js", "static/js/chunks/main.js", "static/js/main.js", "static/main.js"}},
+		{1, []string{"main.js"}},
+		{2, []string{"main.js", "static/main.js"}},
+		{3, []string{"main.js", "static/js/main.js", "static/main.js"}},
+		{10, []string{"main.js", "static/js/chunks/main.js", "static/js/main.js", "static/main.js"}},
+	}
+
+	for _, test := range tests {
+		renv := NewReactenv()
+		renv.MaxDepth = test.maxDepth
+
+		if got := findFiles(t, renv, dir); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("MaxDepth %d found %v, want %v", test.maxDepth, got, test.want)
+		}
+	}
+}
+
+func TestFindFilesIncludeExclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		REACTENV_IGNORE_FILE:  "vendor/\n*.test.js\n",
+		"main.js":             "",
+		"main.test.js":        "",
+		"vendor/lib.js":       "",
+		"static/js/main.js":   "",
+		"static/js/legacy.js": "",
+		"static/css/main.css": "",
+	})
+
+	renv := NewReactenv()
+	if got, want := findFiles(t, renv, dir), []string{"main.js", "static/js/legacy.js", "static/js/main.js"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("found %v, want %v", got, want)
+	}
+
+	renv = NewReactenv()
+	renv.Include = []string{"static/**"}
+	renv.Exclude = []string{"legacy.js"}
+	if got, want := findFiles(t, renv, dir), []string{"static/js/main.js"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("found %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,218 @@
+package reactenv
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// File extensions of HTML files, which reference injected files (see `stageIntegrity`)
+var htmlExtensions = []string{".html", ".htm"}
+
+// An HTML start tag
+type htmlTag struct {
+	// Lowercase tag name, e.g. `script`
+	name  string
+	attrs []htmlAttr
+	// Byte offsets of the tag, from `<` to after `>`
+	start int
+	end   int
+	// Byte offsets of the contents of a `<script>` or `<style>` element (-1 for other tags)
+	contentStart int
+	contentEnd   int
+}
+
+// An attribute of an HTML start tag
+type htmlAttr struct {
+	// Lowercase attribute name
+	name string
+	// Unescaped value
+	value string
+	// Byte offsets of the raw value, without quotes (both -1 if the attribute has no value)
+	valueStart int
+	valueEnd   int
+}
+
+// Returns the attribute `name` of a tag
+func (t *htmlTag) attr(name string) (htmlAttr, bool) {
+	for _, attr := range t.attrs {
+		if attr.name == name {
+			return attr, true
+		}
+	}
+	return htmlAttr{}, false
+}
+
+func isHTMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// Returns every start tag in an HTML document, in order. Comments, and the
+// contents of `<script>` and `<style>` elements, are skipped.
+//
+// This is not a full HTML parser, only enough to find the tags (and their
+// attributes) that reference other files in a built app.
+func scanHTMLTags(contents []byte) []htmlTag {
+	tags := make([]htmlTag, 0)
+
+	for i := 0; i < len(contents); {
+		next := bytes.IndexByte(contents[i:], '<')
+
+		if next < 0 {
+			break
+		}
+
+		i += next
+
+		if bytes.HasPrefix(contents[i:], []byte("<!--")) {
+			end := bytes.Index(contents[i+4:], []byte("-->"))
+			if end < 0 {
+				break
+			}
+			i += 4 + end + 3
+			continue
+		}
+
+		if i+1 >= len(contents) || !isASCIILetter(contents[i+1]) {
+			i++
+			continue
+		}
+
+		tag := parseHTMLTag(contents, i)
+		i = tag.end
+
+		if tag.name == "script" || tag.name == "style" {
+			closeIndex := indexFold(contents[tag.end:], "</"+tag.name)
+			if closeIndex < 0 {
+				closeIndex = len(contents) - tag.end
+			}
+			tag.contentStart, tag.contentEnd = tag.end, tag.end+closeIndex
+			i = tag.contentEnd
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// Parses the start tag at `start` (which must be `<` followed by a letter)
+func parseHTMLTag(contents []byte, start int) htmlTag {
+	tag := htmlTag{start: start, contentStart: -1, contentEnd: -1}
+
+	i := start + 1
+	for i < len(contents) && !isHTMLSpace(contents[i]) && contents[i] != '>' && contents[i] != '/' {
+		i++
+	}
+	tag.name = strings.ToLower(string(contents[start+1 : i]))
+
+	for i < len(contents) {
+		for i < len(contents) && (isHTMLSpace(contents[i]) || contents[i] == '/') {
+			i++
+		}
+
+		if i >= len(contents) {
+			break
+		}
+
+		if contents[i] == '>' {
+			i++
+			break
+		}
+
+		nameStart := i
+		for i < len(contents) && !isHTMLSpace(contents[i]) && contents[i] != '>' && contents[i] != '/' && (contents[i] != '=' || i == nameStart) {
+			i++
+		}
+
+		attr := htmlAttr{name: strings.ToLower(string(contents[nameStart:i])), valueStart: -1, valueEnd: -1}
+
+		valueIndex := i
+		for valueIndex < len(contents) && isHTMLSpace(contents[valueIndex]) {
+			valueIndex++
+		}
+
+		if valueIndex < len(contents) && contents[valueIndex] == '=' {
+			i = valueIndex + 1
+			for i < len(contents) && isHTMLSpace(contents[i]) {
+				i++
+			}
+
+			if i < len(contents) && (contents[i] == '"' || contents[i] == '\'') {
+				quote := contents[i]
+				end := bytes.IndexByte(contents[i+1:], quote)
+				if end < 0 {
+					end = len(contents) - i - 1
+				}
+				attr.valueStart, attr.valueEnd = i+1, i+1+end
+				i = min(attr.valueEnd+1, len(contents))
+			} else {
+				attr.valueStart = i
+				for i < len(contents) && !isHTMLSpace(contents[i]) && contents[i] != '>' {
+					i++
+				}
+				attr.valueEnd = i
+			}
+
+			attr.value = html.UnescapeString(string(contents[attr.valueStart:attr.valueEnd]))
+		}
+
+		tag.attrs = append(tag.attrs, attr)
+	}
+
+	tag.end = i
+	return tag
+}
+
+// Returns the index of the first case-insensitive match of (ASCII) `sub` in `contents`
+func indexFold(contents []byte, sub string) int {
+	for i := 0; i+len(sub) <= len(contents); i++ {
+		next := bytes.IndexByte(contents[i:], sub[0])
+		if next < 0 {
+			return -1
+		}
+		i += next
+		if i+len(sub) <= len(contents) && strings.EqualFold(string(contents[i:i+len(sub)]), sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the path (relative to `Reactenv.Dir`) of a file referenced by a URL
+// from the file at `relPath`. Root-relative URLs (e.g. `/static/js/main.js`)
+// are relative to `Dir`. URLs to other hosts (and data URLs) are not supported.
+func resolveURL(relPath string, reference string) (string, bool) {
+	referenceURL, err := url.Parse(reference)
+
+	if err != nil || referenceURL.Scheme != "" || referenceURL.Host != "" || referenceURL.Path == "" {
+		return "", false
+	}
+
+	referencePath := path.Join(path.Dir(relPath), referenceURL.Path)
+
+	// As in browsers, `..` can not go above the root
+	if rootPath, ok := strings.CutPrefix(path.Clean(referenceURL.Path), "/"); ok {
+		referencePath = path.Clean(rootPath)
+	}
+
+	if referencePath == "." || referencePath == ".." || strings.HasPrefix(referencePath, "../") {
+		return "", false
+	}
+
+	return referencePath, true
+}
+
+// Returns the path (relative to `Reactenv.Dir`) of every HTML file in `Dir`
+func (r *Reactenv) findHTMLFiles() ([]string, error) {
+	return r.findFilesByName(func(name string) bool {
+		return slices.Contains(htmlExtensions, strings.ToLower(path.Ext(name)))
+	})
+}
@@ -0,0 +1,177 @@
+package reactenv
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"html"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Subresource Integrity hash algorithms (https://www.w3.org/TR/SRI/)
+var integrityAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// `<link>` types that support (or require) an `integrity` attribute
+var integrityLinkTypes = []string{"stylesheet", "preload", "modulepreload"}
+
+// Updates the `integrity` attribute of every `<script>` and `<link>` tag (in
+// every HTML file in `Dir`) that references a changed file, using the same
+// algorithms. Tags that reference a changed file without an `integrity`
+// attribute are added to `Reactenv.Warnings`. Returns the path of every updated HTML file.
+func (r *Reactenv) stageIntegrity(tx *Transaction) ([]string, error) {
+	htmlPaths, err := r.findHTMLFiles()
+
+	if err != nil {
+		return nil, err
+	}
+
+	changed := r.changedFiles()
+	digests := make(map[string]string)
+	updated := make([]string, 0)
+
+	for _, htmlPath := range htmlPaths {
+		ok, err := r.stageProcessed(tx, htmlPath, func(contents []byte) ([]byte, error) {
+			return r.updateIntegrity(tx, htmlPath, contents, changed, digests)
+		})
+
+		if err != nil {
+			return nil, &FileError{Path: htmlPath, Op: "update integrity hashes in", Err: err}
+		}
+
+		if ok {
+			updated = append(updated, htmlPath)
+		}
+	}
+
+	return updated, nil
+}
+
+// Returns the contents of an HTML file, with the `integrity` attribute of every tag that references a changed file updated
+func (r *Reactenv) updateIntegrity(tx *Transaction, htmlPath string, contents []byte, changed map[string]*File, digests map[string]string) ([]byte, error) {
+	contentsNew := make([]byte, 0, len(contents))
+	lastIndex := 0
+
+	for _, tag := range scanHTMLTags(contents) {
+		referenceAttr := "src"
+		if tag.name == "link" {
+			referenceAttr = "href"
+		} else if tag.name != "script" {
+			continue
+		}
+
+		reference, ok := tag.attr(referenceAttr)
+
+		if !ok {
+			continue
+		}
+
+		referencePath, ok := resolveURL(htmlPath, reference.value)
+
+		if !ok {
+			continue
+		}
+
+		file, ok := changed[referencePath]
+
+		if !ok {
+			continue
+		}
+
+		integrity, ok := tag.attr("integrity")
+
+		if !ok || integrity.valueStart < 0 {
+			if tag.name == "script" || isIntegrityLink(tag) {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("'%s' references '%s', which has changed, but has no integrity attribute to update", htmlPath, referencePath))
+			}
+			continue
+		}
+
+		value, err := r.recomputeIntegrity(tx, file, integrity.value, digests)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to hash '%s': %w", file.Path, err)
+		}
+
+		contentsNew = append(contentsNew, contents[lastIndex:integrity.valueStart]...)
+		contentsNew = append(contentsNew, html.EscapeString(value)...)
+		lastIndex = integrity.valueEnd
+	}
+
+	return append(contentsNew, contents[lastIndex:]...), nil
+}
+
+// Reports whether a `<link>` tag is of a type that supports an `integrity` attribute
+func isIntegrityLink(tag htmlTag) bool {
+	rel, ok := tag.attr("rel")
+	if !ok {
+		return false
+	}
+	for _, linkType := range strings.Fields(strings.ToLower(rel.value)) {
+		if slices.Contains(integrityLinkTypes, linkType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns an `integrity` value with every hash recomputed from the staged
+// contents of `file`. Hashes using an unknown algorithm are left as they are.
+func (r *Reactenv) recomputeIntegrity(tx *Transaction, file *File, integrity string, digests map[string]string) (string, error) {
+	hashes := strings.Fields(integrity)
+
+	for i, integrityHash := range hashes {
+		algorithm, rest, ok := strings.Cut(integrityHash, "-")
+		_, options, _ := strings.Cut(rest, "?")
+
+		if !ok || integrityAlgorithms[algorithm] == nil {
+			continue
+		}
+
+		digest, err := r.integrityDigest(tx, file, algorithm, digests)
+
+		if err != nil {
+			return "", err
+		}
+
+		hashes[i] = algorithm + "-" + digest
+		if options != "" {
+			hashes[i] += "?" + options
+		}
+	}
+
+	return strings.Join(hashes, " "), nil
+}
+
+// Returns the base64 digest of the staged contents of `file` (cached in `digests`)
+func (r *Reactenv) integrityDigest(tx *Transaction, file *File, algorithm string, digests map[string]string) (string, error) {
+	key := algorithm + ":" + file.Path
+
+	if digest, ok := digests[key]; ok {
+		return digest, nil
+	}
+
+	reader, err := r.openStaged(tx, file)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer reader.Close()
+
+	digest := integrityAlgorithms[algorithm]()
+
+	if _, err := io.Copy(digest, reader); err != nil {
+		return "", err
+	}
+
+	digests[key] = base64.StdEncoding.EncodeToString(digest.Sum(nil))
+	return digests[key], nil
+}
@@ -0,0 +1,172 @@
+package reactenv
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Returns the `integrity` hash of `contents` using `algorithm`
+func integrityHash(algorithm string, contents string) string {
+	digest := integrityAlgorithms[algorithm]()
+	digest.Write([]byte(contents))
+	return algorithm + "-" + base64.StdEncoding.EncodeToString(digest.Sum(nil))
+}
+
+func TestScanHTMLTags(t *testing.T) {
+	contents := `<!doctype html><HTML lang=en><head>
+<!-- <script src="commented.js"></script> -->
+<script defer src = "/a.js" integrity='sha256-x y'></script>
+<link rel=stylesheet href=b.css crossorigin>
+<script>if (a<b) { document.write('<link href="c.css">') }</SCRIPT>
+<style>a > b { color: red }</style>
+<img src="a&amp;b.png"/>
+</head></html>`
+
+	type attr struct {
+		name  string
+		value string
+		raw   string
+	}
+
+	want := []struct {
+		name    string
+		attrs   []attr
+		content string
+	}{
+		{"html", []attr{{"lang", "en", "en"}}, ""},
+		{"head", nil, ""},
+		{"script", []attr{{"defer", "", ""}, {"src", "/a.js", "/a.js"}, {"integrity", "sha256-x y", "sha256-x y"}}, ""},
+		{"link", []attr{{"rel", "stylesheet", "stylesheet"}, {"href", "b.css", "b.css"}, {"crossorigin", "", ""}}, ""},
+		{"script", nil, `if (a<b) { document.write('<link href="c.css">') }`},
+		{"style", nil, `a > b { color: red }`},
+		{"img", []attr{{"src", "a&b.png", "a&amp;b.png"}}, ""},
+	}
+
+	tags := scanHTMLTags([]byte(contents))
+
+	if len(tags) != len(want) {
+		t.Fatalf("found %d tags, want %d", len(tags), len(want))
+	}
+
+	for i, tag := range tags {
+		if tag.name != want[i].name {
+			t.Errorf("tag %d is <%s>, want <%s>", i, tag.name, want[i].name)
+			continue
+		}
+
+		got := make([]attr, 0)
+		for _, a := range tag.attrs {
+			raw := ""
+			if a.valueStart >= 0 {
+				raw = contents[a.valueStart:a.valueEnd]
+			}
+			got = append(got, attr{a.name, a.value, raw})
+		}
+
+		if len(got) != len(want[i].attrs) || (len(got) > 0 && !reflect.DeepEqual(got, want[i].attrs)) {
+			t.Errorf("<%s> has attributes %+v, want %+v", tag.name, got, want[i].attrs)
+		}
+
+		content := ""
+		if tag.contentStart >= 0 {
+			content = contents[tag.contentStart:tag.contentEnd]
+		}
+
+		if content != want[i].content {
+			t.Errorf("<%s> contains %q, want %q", tag.name, content, want[i].content)
+		}
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		relPath   string
+		reference string
+		want      string
+	}{
+		{"index.html", "static/js/main.js", "static/js/main.js"},
+		{"index.html", "./static/js/main.js", "static/js/main.js"},
+		{"index.html", "/static/js/main.js", "static/js/main.js"},
+		{"index.html", "/static/js/main.js?v=1#top", "static/js/main.js"},
+		{"pages/about.html", "main.js", "pages/main.js"},
+		{"pages/about.html", "../static/main.js", "static/main.js"},
+		{"pages/about.html", "/static/main.js", "static/main.js"},
+		{"pages/about.html", "/../static/main.js", "static/main.js"},
+		// Other hosts, data URLs, and paths outside of `Dir` are not resolved
+		{"index.html", "https://cdn.example.com/main.js", ""},
+		{"index.html", "//cdn.example.com/main.js", ""},
+		{"index.html", "data:text/javascript,a", ""},
+		{"index.html", "../main.js", ""},
+		{"index.html", "#top", ""},
+		{"index.html", "/", ""},
+	}
+
+	for _, test := range tests {
+		got, ok := resolveURL(test.relPath, test.reference)
+
+		if ok != (test.want != "") || got != test.want {
+			t.Errorf("resolveURL(%q, %q) = %q, %v, want %q", test.relPath, test.reference, got, ok, test.want)
+		}
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	dir := t.TempDir()
+
+	mainJS := `a="__reactenv.A"`
+	chunkJS := `b="__reactenv.A"`
+	vendorJS := `c=1`
+	mainCSS := `a::after{content:"__reactenv.A"}`
+
+	writeFiles(t, dir, map[string]string{
+		"static/js/main.js":   mainJS,
+		"static/js/chunk.js":  chunkJS,
+		"static/js/vendor.js": vendorJS,
+		"static/css/main.css": mainCSS,
+		"index.html": `<!doctype html><html><head>
+<script defer src="/static/js/main.js" integrity="` + integrityHash("sha384", mainJS) + ` ` + integrityHash("sha512", mainJS) + `" crossorigin="anonymous"></script>
+<script src=static/js/vendor.js integrity=` + integrityHash("sha256", vendorJS) + `></script>
+<link rel="preload stylesheet" href='./static/css/main.css' integrity='` + integrityHash("sha256", mainCSS) + `?opt md5-abc'>
+<link rel=icon href=/static/js/chunk.js>
+<script src="https://cdn.example.com/static/js/main.js" integrity="sha256-cdn"></script>
+<script src="/static/js/chunk.js"></script>
+</head></html>`,
+		"pages/about.html": `<script src="../static/js/chunk.js" integrity="` + integrityHash("sha256", chunkJS) + `"></script>`,
+	})
+
+	renv := NewReactenv()
+	inject(t, renv, dir, []string{".js,.css"}, map[string]string{"A": "a"})
+
+	mainJSNew := `a="a"`
+	chunkJSNew := `b="a"`
+	mainCSSNew := `a::after{content:"a"}`
+
+	want := map[string]string{
+		"index.html": `<!doctype html><html><head>
+<script defer src="/static/js/main.js" integrity="` + integrityHash("sha384", mainJSNew) + ` ` + integrityHash("sha512", mainJSNew) + `" crossorigin="anonymous"></script>
+<script src=static/js/vendor.js integrity=` + integrityHash("sha256", vendorJS) + `></script>
+<link rel="preload stylesheet" href='./static/css/main.css' integrity='` + integrityHash("sha256", mainCSSNew) + `?opt md5-abc'>
+<link rel=icon href=/static/js/chunk.js>
+<script src="https://cdn.example.com/static/js/main.js" integrity="sha256-cdn"></script>
+<script src="/static/js/chunk.js"></script>
+</head></html>`,
+		"pages/about.html": `<script src="../static/js/chunk.js" integrity="` + integrityHash("sha256", chunkJSNew) + `"></script>`,
+	}
+
+	for relPath, contents := range want {
+		if got := readFile(t, dir, relPath); got != contents {
+			t.Errorf("'%s' contains\n%s\nwant\n%s", relPath, got, contents)
+		}
+	}
+
+	if !reflect.DeepEqual(renv.IntegrityFiles, []string{"index.html", "pages/about.html"}) {
+		t.Errorf("updated %v, want 'index.html' and 'pages/about.html'", renv.IntegrityFiles)
+	}
+
+	// Only the `<script>` without an integrity attribute is a warning (icons do not support one)
+	if len(renv.Warnings) != 1 || !strings.Contains(renv.Warnings[0], "'index.html' references 'static/js/chunk.js'") {
+		t.Errorf("warnings are %q, want one for the script without an integrity attribute", renv.Warnings)
+	}
+}
@@ -0,0 +1,262 @@
+package reactenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returns the path a File is written to (within `Reactenv.OutDir` if set, otherwise in place)
+func (r *Reactenv) OutPath(file *File) string {
+	return r.outPathRel(file.Path)
+}
+
+func (r *Reactenv) outPathRel(relPath string) string {
+	if r.OutDir == "" {
+		return filepath.Join(r.Dir, filepath.FromSlash(relPath))
+	}
+	return filepath.Join(r.OutDir, filepath.FromSlash(relPath))
+}
+
+// Templates are only kept when files are injected in place (`Dir` is left untouched when using `OutDir`)
+func (r *Reactenv) keepTemplates() bool {
+	return r.templateManifest != nil && r.OutDir == ""
+}
+
+// Stages the injected contents of a file (written by `write`), either in place or within `Reactenv.OutDir`.
+//
+// Compressed files are recompressed (see `Reactenv.Compressed`), and any
+// compressed siblings are regenerated (see `stageCompressedSiblings`).
+func (r *Reactenv) stageInjected(tx *Transaction, file *File, write func(w io.Writer) error) error {
+	if file.Compression != CompressionNone {
+		options, err := readCompressionOptions(r.FilePath(file), file.Compression)
+
+		if err != nil {
+			return err
+		}
+
+		return r.stagePath(tx, r.FilePath(file), r.OutPath(file), compressWrite(options, write))
+	}
+
+	if err := r.stagePath(tx, r.FilePath(file), r.OutPath(file), write); err != nil {
+		return err
+	}
+
+	return r.stageCompressedSiblings(tx, file.Path)
+}
+
+// Stages new contents for `sourcePath`, either in place or at `outPath` (when using `Reactenv.OutDir`)
+func (r *Reactenv) stagePath(tx *Transaction, sourcePath string, outPath string, write func(w io.Writer) error) error {
+	if r.OutDir == "" {
+		return tx.Stage(sourcePath, write)
+	}
+
+	info, err := os.Stat(sourcePath)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.StageLike(outPath, info, write)
+}
+
+// Reports whether `dirPath` is `Reactenv.OutDir` (which is never scanned, or copied)
+func (r *Reactenv) isOutDir(dirPath string) bool {
+	return r.OutDir != "" && isSamePath(dirPath, r.OutDir)
+}
+
+func isSamePath(a string, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
+
+// Returns the absolute, symlink-free path of `filePath`
+func realFilePath(filePath string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(resolved)
+}
+
+// Returns the absolute, symlink-free path of `dirPath` (which may not exist yet)
+func realDirPath(dirPath string) (string, error) {
+	dirPath, err := filepath.Abs(dirPath)
+
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(dirPath)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		parent := filepath.Dir(dirPath)
+		if parent == dirPath {
+			return dirPath, nil
+		}
+		realParent, err := realDirPath(parent)
+		return filepath.Join(realParent, filepath.Base(dirPath)), err
+	}
+
+	return resolved, err
+}
+
+// Reports whether `path` is `dir`, or within it (both must be absolute and clean)
+func isWithinDir(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Checks `Reactenv.OutDir` can be written to, without overwriting anything in `Reactenv.Dir`
+func (r *Reactenv) validateOutDir() error {
+	dir, err := realDirPath(r.Dir)
+
+	if err != nil {
+		return err
+	}
+
+	outDir, err := realDirPath(r.OutDir)
+
+	if err != nil {
+		return err
+	}
+
+	if isWithinDir(dir, outDir) {
+		return fmt.Errorf("output directory '%s' must not be (or contain) the scanned directory '%s'", r.OutDir, r.Dir)
+	}
+
+	return nil
+}
+
+// Stages a copy of every file in `Reactenv.Dir` (except injected files) within `Reactenv.OutDir`.
+//
+// Directories are created straight away (and removed if the transaction is
+// rolled back). Files are staged as hard links
+// (or copies, where hard links are not supported), and symlinks to files are
+// staged as regular files, so nothing is ever written through a symlink into
+// `Dir`. Other paths to an injected file, and symlinks to directories within
+// `Dir`, are staged as relative symlinks within `OutDir`.
+func (r *Reactenv) stageOutDir(ctx context.Context, tx *Transaction) error {
+	realDir, err := realDirPath(r.Dir)
+
+	if err != nil {
+		return err
+	}
+
+	dirInfo, err := os.Stat(r.Dir)
+
+	if err != nil {
+		return err
+	}
+
+	if err := tx.MkdirAll(r.OutDir, dirInfo.Mode().Perm()); err != nil {
+		return err
+	}
+
+	// Injected files, by real path, so other paths to the same file point to the injected copy
+	injected := make(map[string]string)
+	for _, file := range r.Files {
+		realPath, err := realFilePath(r.FilePath(file))
+		if err != nil {
+			return err
+		}
+		if _, ok := injected[realPath]; !ok {
+			injected[realPath] = file.Path
+		}
+	}
+
+	return filepath.WalkDir(r.Dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(r.Dir, filePath)
+
+		if err != nil {
+			return err
+		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		outPath := filepath.Join(r.OutDir, relPath)
+
+		if entry.IsDir() {
+			if r.isTemplateDir(filePath) || r.isOutDir(filePath) {
+				return fs.SkipDir
+			}
+
+			info, err := entry.Info()
+
+			if err != nil {
+				return err
+			}
+
+			return tx.MkdirAll(outPath, info.Mode().Perm())
+		}
+
+		realPath, err := realFilePath(filePath)
+
+		// Dangling symlink, copy as-is
+		if errors.Is(err, fs.ErrNotExist) && entry.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(filePath)
+			if err != nil {
+				return err
+			}
+			return tx.StageSymlink(outPath, target)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if injectedPath, ok := injected[realPath]; ok {
+			if injectedPath == filepath.ToSlash(relPath) {
+				return nil
+			}
+			return tx.StageSymlink(outPath, relativeLink(r.OutDir, relPath, injectedPath))
+		}
+
+		info, err := os.Stat(realPath)
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir() && isWithinDir(realPath, realDir):
+			realRelPath, err := filepath.Rel(realDir, realPath)
+			if err != nil {
+				return err
+			}
+			return tx.StageSymlink(outPath, relativeLink(r.OutDir, relPath, filepath.ToSlash(realRelPath)))
+		case info.IsDir():
+			return tx.StageSymlink(outPath, realPath)
+		case info.Mode().IsRegular():
+			return tx.StageLink(outPath, realPath)
+		}
+
+		// Sockets, devices, etc. are not copied
+		return nil
+	})
+}
+
+// Returns a symlink target (for a link at `relPath`) pointing to `targetRelPath`, both relative to `outDir`
+func relativeLink(outDir string, relPath string, targetRelPath string) string {
+	target, err := filepath.Rel(filepath.Join(outDir, filepath.Dir(relPath)), filepath.Join(outDir, filepath.FromSlash(targetRelPath)))
+	if err != nil {
+		return filepath.Join(outDir, filepath.FromSlash(targetRelPath))
+	}
+	return target
+}
@@ -0,0 +1,163 @@
+package reactenv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Finds files in `dir` matching `fileMatchExpressions`, and injects `values` into them
+func inject(t *testing.T, renv *Reactenv, dir string, fileMatchExpressions []string, values map[string]string) {
+	t.Helper()
+
+	renv.Values = MapSource(values)
+
+	if err := renv.FindFiles(context.Background(), dir, fileMatchExpressions); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := renv.FindOccurrences(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := renv.ReplaceOccurrences(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Reads a file within `dir`
+func readFile(t *testing.T, dir string, relPath string) string {
+	t.Helper()
+
+	contents, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(relPath)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(contents)
+}
+
+func TestOutDir(t *testing.T) {
+	dir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	files := map[string]string{
+		"index.js":        `a="__reactenv.A"`,
+		"chunks/b.js":     `b="__reactenv.B",c=1`,
+		"chunks/plain.js": `c=1`,
+		"index.css":       `a{color:red}`,
+		"logo.png":        "\x89PNG",
+	}
+	writeFiles(t, dir, files)
+
+	// Source files keep their modification times
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for relPath := range files {
+		if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(relPath)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	renv := NewReactenv()
+	renv.OutDir = outDir
+	inject(t, renv, dir, nil, map[string]string{"A": "a", "B": "b"})
+
+	for relPath, contents := range files {
+		sourcePath := filepath.Join(dir, filepath.FromSlash(relPath))
+
+		if got := readFile(t, dir, relPath); got != contents {
+			t.Errorf("source '%s' contains %q, want %q (unchanged)", relPath, got, contents)
+		}
+
+		if info, err := os.Stat(sourcePath); err != nil || !info.ModTime().Equal(mtime) {
+			t.Errorf("source '%s' was modified (%v)", relPath, err)
+		}
+	}
+
+	want := map[string]string{
+		"index.js":        `a="a"`,
+		"chunks/b.js":     `b="b",c=1`,
+		"chunks/plain.js": `c=1`,
+		"index.css":       `a{color:red}`,
+		"logo.png":        "\x89PNG",
+	}
+
+	for relPath, contents := range want {
+		if got := readFile(t, outDir, relPath); got != contents {
+			t.Errorf("'%s' contains %q, want %q", relPath, got, contents)
+		}
+
+		sourceInfo, err := os.Stat(filepath.Join(dir, filepath.FromSlash(relPath)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		outInfo, err := os.Stat(filepath.Join(outDir, filepath.FromSlash(relPath)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Unchanged files are hard links to the source, injected files are new files
+		linked := contents == files[relPath]
+		if same := os.SameFile(sourceInfo, outInfo); same != linked {
+			t.Errorf("'%s' shares an inode with the source: %v, want %v", relPath, same, linked)
+		}
+	}
+}
+
+// A symlink to an injected file points to the injected copy, never into the source
+func TestOutDirSymlink(t *testing.T) {
+	dir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	writeFiles(t, dir, map[string]string{"index.js": `a="__reactenv.A"`})
+
+	if err := os.Symlink("index.js", filepath.Join(dir, "main.js")); err != nil {
+		t.Skip("symlinks are not supported", err)
+	}
+
+	renv := NewReactenv()
+	renv.OutDir = outDir
+	inject(t, renv, dir, []string{`^index\.js$`}, map[string]string{"A": "a"})
+
+	if got := readFile(t, outDir, "main.js"); got != `a="a"` {
+		t.Errorf("'main.js' contains %q, want the injected file", got)
+	}
+
+	if target, err := os.Readlink(filepath.Join(outDir, "main.js")); err != nil || target != "index.js" {
+		t.Errorf("'main.js' links to %q (%v), want \"index.js\"", target, err)
+	}
+
+	if got := readFile(t, dir, "index.js"); got != `a="__reactenv.A"` {
+		t.Errorf("source 'index.js' contains %q, want it unchanged", got)
+	}
+}
+
+// The output directory must not contain the scanned directory
+func TestOutDirWithinDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"app/index.js": `a="__reactenv.A"`})
+
+	renv := NewReactenv()
+	renv.OutDir = dir
+	renv.Values = MapSource{"A": "a"}
+
+	if err := renv.FindFiles(context.Background(), filepath.Join(dir, "app"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := renv.FindOccurrences(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := renv.ReplaceOccurrences(context.Background()); err == nil {
+		t.Errorf("ReplaceOccurrences into a parent of the scanned directory succeeded")
+	}
+
+	if got := readFile(t, dir, "app/index.js"); got != `a="__reactenv.A"` {
+		t.Errorf("source 'index.js' contains %q, want it unchanged", got)
+	}
+}
@@ -0,0 +1,59 @@
+package reactenv
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// Returns the number of files processed at once
+func (r *Reactenv) concurrency() int {
+	if r.Concurrency > 0 {
+		return r.Concurrency
+	}
+	return runtime.NumCPU()
+}
+
+// Runs `fileCb` for every File, using a bounded pool of workers (see `Reactenv.Concurrency`).
+//
+// Every file is processed, even if one fails. Returns the error of the
+// first failed file (in file order), so errors are deterministic. If `ctx` is
+// cancelled, no more files are started and its error is returned.
+func (r *Reactenv) FilesWalkParallel(ctx context.Context, fileCb func(fileIndex int, file *File) error) error {
+	errs := make([]error, len(r.Files))
+	fileIndexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < min(r.concurrency(), len(r.Files)); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileIndex := range fileIndexes {
+				errs[fileIndex] = fileCb(fileIndex, r.Files[fileIndex])
+			}
+		}()
+	}
+
+dispatch:
+	for fileIndex := range r.Files {
+		select {
+		case fileIndexes <- fileIndex:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(fileIndexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package reactenv
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Service worker file names that may contain a precache manifest (`*` matches any hash)
+var precacheFileNames = []string{"service-worker.js", "precache-manifest.*.js"}
+
+var (
+	// Matches an object without nested objects, e.g. `{url:"/index.html",revision:"abc"}`
+	precacheEntryExpression = regexp.MustCompile(`\{[^{}]*\}`)
+	// Matches the `url` of a precache manifest entry
+	precacheURLExpression = regexp.MustCompile(`(?:^|[{,\s])["']?url["']?\s*:\s*("[^"\n]*"|'[^'\n]*')`)
+	// Matches the `revision` of a precache manifest entry
+	precacheRevisionExpression = regexp.MustCompile(`(?:^|[{,\s])["']?revision["']?\s*:\s*("[^"\n]*"|'[^'\n]*'|null)`)
+)
+
+// Reports whether `name` is a service worker file that may contain a precache manifest
+func isPrecacheFile(name string) bool {
+	for _, precacheFileName := range precacheFileNames {
+		prefix, suffix, ok := strings.Cut(precacheFileName, "*")
+		if name == precacheFileName || (ok && len(name) > len(prefix)+len(suffix) && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Updates the `revision` of every changed file in the precache manifests of
+// service workers in `Dir` (see `precacheFileNames`), as used by Workbox and
+// Create React App, so returning users do not keep cached copies from before
+// injection. Revisions are the md5 of the contents (as Workbox uses). Entries
+// with a `null` revision (where the URL alone identified its contents) are
+// given one. Returns the path of every updated file.
+func (r *Reactenv) stagePrecache(tx *Transaction) ([]string, error) {
+	precachePaths, err := r.findFilesByName(isPrecacheFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	changed := r.changedFiles()
+	revisions := make(map[string]string)
+	updated := make([]string, 0)
+
+	for _, precachePath := range precachePaths {
+		ok, err := r.stageProcessed(tx, precachePath, func(contents []byte) ([]byte, error) {
+			return r.updatePrecache(tx, precachePath, contents, changed, revisions)
+		})
+
+		if err != nil {
+			return nil, &FileError{Path: precachePath, Op: "update precache revisions in", Err: err}
+		}
+
+		if ok {
+			updated = append(updated, precachePath)
+		}
+	}
+
+	return updated, nil
+}
+
+// Returns the contents of a service worker, with the revision of every precache manifest entry for a changed file updated
+func (r *Reactenv) updatePrecache(tx *Transaction, precachePath string, contents []byte, changed map[string]*File, revisions map[string]string) ([]byte, error) {
+	contentsNew := make([]byte, 0, len(contents))
+	lastIndex := 0
+
+	for _, entry := range precacheEntryExpression.FindAllIndex(contents, -1) {
+		object := contents[entry[0]:entry[1]]
+		urlMatch := precacheURLExpression.FindSubmatchIndex(object)
+		revisionMatch := precacheRevisionExpression.FindSubmatchIndex(object)
+
+		if urlMatch == nil || revisionMatch == nil {
+			continue
+		}
+
+		entryURL := string(object[urlMatch[2]+1 : urlMatch[3]-1])
+		referencePath, ok := resolveURL(precachePath, entryURL)
+
+		if !ok {
+			continue
+		}
+
+		file, ok := changed[referencePath]
+
+		if !ok {
+			continue
+		}
+
+		revision, err := r.precacheRevision(tx, file, revisions)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to hash '%s': %w", file.Path, err)
+		}
+
+		// Keep the quotes of the original revision (or use double quotes, if it was `null`)
+		quote := object[revisionMatch[2]]
+		if quote != '"' && quote != '\'' {
+			quote = '"'
+		}
+
+		contentsNew = append(contentsNew, contents[lastIndex:entry[0]+revisionMatch[2]]...)
+		contentsNew = append(contentsNew, quote)
+		contentsNew = append(contentsNew, revision...)
+		contentsNew = append(contentsNew, quote)
+		lastIndex = entry[0] + revisionMatch[3]
+	}
+
+	return append(contentsNew, contents[lastIndex:]...), nil
+}
+
+// Returns the md5 (as hex) of the staged contents of `file` (cached in `revisions`)
+func (r *Reactenv) precacheRevision(tx *Transaction, file *File, revisions map[string]string) (string, error) {
+	if revision, ok := revisions[file.Path]; ok {
+		return revision, nil
+	}
+
+	reader, err := r.openStaged(tx, file)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer reader.Close()
+
+	digest := md5.New()
+
+	if _, err := io.Copy(digest, reader); err != nil {
+		return "", err
+	}
+
+	revisions[file.Path] = hex.EncodeToString(digest.Sum(nil))
+	return revisions[file.Path], nil
+}
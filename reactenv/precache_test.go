@@ -0,0 +1,125 @@
+package reactenv
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// Returns the precache revision (md5) of `contents`
+func md5Revision(contents string) string {
+	digest := md5.Sum([]byte(contents))
+	return hex.EncodeToString(digest[:])
+}
+
+func TestIsPrecacheFile(t *testing.T) {
+	tests := map[string]bool{
+		"service-worker.js":                   true,
+		"precache-manifest.js":                false,
+		"precache-manifest.0a1b2c.js":         true,
+		"precache-manifest.0a1b2c.js.map":     false,
+		"sw.js":                               false,
+		"my-service-worker.js":                false,
+		"precache-manifest..js":               false,
+		"precache-manifest.0a1b2c.js.LICENSE": false,
+	}
+
+	for name, want := range tests {
+		if got := isPrecacheFile(name); got != want {
+			t.Errorf("isPrecacheFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPrecache(t *testing.T) {
+	dir := t.TempDir()
+
+	mainJS := `a="__reactenv.A"`
+	chunkJS := `b="__reactenv.A"`
+	indexHTML := `<title>__reactenv.A</title>`
+
+	writeFiles(t, dir, map[string]string{
+		"index.html":            indexHTML,
+		"static/js/main.js":     mainJS,
+		"static/js/chunk.js":    chunkJS,
+		"static/js/vendor.js":   `c=1`,
+		"static/media/logo.svg": `<svg/>`,
+		// Workbox (`injectManifest`), with `revision` before and after `url`
+		"service-worker.js": `importScripts("workbox.js");precacheAndRoute([{revision:"` + md5Revision(indexHTML) + `",url:"/index.html"},{url:"/static/js/main.js",revision:null},{revision:'old',url:'./static/js/chunk.js'},{url:"/static/js/vendor.js",revision:"vendor"},{url:"/static/media/logo.svg",revision:"logo"}]||[]);`,
+		// Create React App (workbox-webpack-plugin v4), in a separate file
+		"precache-manifest.0a1b2c.js": `self.__precacheManifest = (self.__precacheManifest || []).concat([
+  {
+    "revision": "` + md5Revision(indexHTML) + `",
+    "url": "/index.html"
+  },
+  {
+    "url": "/static/js/main.js",
+    "revision": "main"
+  },
+  {
+    "revision": "vendor",
+    "url": "/static/js/vendor.js"
+  }
+]);`,
+	})
+
+	renv := NewReactenv()
+	inject(t, renv, dir, []string{".js,.html"}, map[string]string{"A": "a"})
+
+	indexRevision := md5Revision(`<title>a</title>`)
+	mainRevision := md5Revision(`a="a"`)
+	chunkRevision := md5Revision(`b="a"`)
+
+	want := map[string]string{
+		"service-worker.js": `importScripts("workbox.js");precacheAndRoute([{revision:"` + indexRevision + `",url:"/index.html"},{url:"/static/js/main.js",revision:"` + mainRevision + `"},{revision:'` + chunkRevision + `',url:'./static/js/chunk.js'},{url:"/static/js/vendor.js",revision:"vendor"},{url:"/static/media/logo.svg",revision:"logo"}]||[]);`,
+		"precache-manifest.0a1b2c.js": `self.__precacheManifest = (self.__precacheManifest || []).concat([
+  {
+    "revision": "` + indexRevision + `",
+    "url": "/index.html"
+  },
+  {
+    "url": "/static/js/main.js",
+    "revision": "` + mainRevision + `"
+  },
+  {
+    "revision": "vendor",
+    "url": "/static/js/vendor.js"
+  }
+]);`,
+	}
+
+	for relPath, contents := range want {
+		if got := readFile(t, dir, relPath); got != contents {
+			t.Errorf("'%s' contains\n%s\nwant\n%s", relPath, got, contents)
+		}
+	}
+
+	if !reflect.DeepEqual(renv.PrecacheFiles, []string{"precache-manifest.0a1b2c.js", "service-worker.js"}) {
+		t.Errorf("updated %v, want both service worker files", renv.PrecacheFiles)
+	}
+}
+
+// Service workers without changed files are left as they are
+func TestPrecacheUnchanged(t *testing.T) {
+	dir := t.TempDir()
+
+	serviceWorker := `precacheAndRoute([{url:"/vendor.js",revision:"vendor"},{url:"https://cdn.example.com/main.js",revision:"cdn"}]);`
+
+	writeFiles(t, dir, map[string]string{
+		"main.js":           `a="__reactenv.A"`,
+		"vendor.js":         `c=1`,
+		"service-worker.js": serviceWorker,
+	})
+
+	renv := NewReactenv()
+	inject(t, renv, dir, nil, map[string]string{"A": "a"})
+
+	if got := readFile(t, dir, "service-worker.js"); got != serviceWorker {
+		t.Errorf("'service-worker.js' contains %s, want it unchanged", got)
+	}
+
+	if len(renv.PrecacheFiles) != 0 {
+		t.Errorf("updated %v, want none", renv.PrecacheFiles)
+	}
+}
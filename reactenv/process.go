@@ -0,0 +1,129 @@
+package reactenv
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path/filepath"
+)
+
+// Stages the contents of a file changed after injection (e.g. a source map,
+// or an HTML file), as returned by `process`. Files already staged (injected,
+// or changed by an earlier step) are processed from their staged contents,
+// other files from their template (if re-injecting). Returns false if
+// `process` left the contents unchanged.
+//
+// The template of the file is kept, so it can be restored along with every
+// injected file.
+func (r *Reactenv) stageProcessed(tx *Transaction, relPath string, process func(contents []byte) ([]byte, error)) (bool, error) {
+	file, staged := r.staged[relPath]
+
+	var contents []byte
+	var err error
+
+	if staged {
+		contents, err = r.readStaged(tx, file)
+	} else {
+		file = &File{Path: relPath, Syntax: SyntaxFromPath(relPath)}
+		contents, err = r.ReadFile(file)
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	contentsNew, err := process(contents)
+
+	if err != nil || bytes.Equal(contents, contentsNew) {
+		return false, err
+	}
+
+	if r.keepTemplates() && staged {
+		r.updateRendered(file.Path, hashContents(contentsNew))
+	} else if r.keepTemplates() {
+		if err := r.stageTemplate(tx, file, hashContents(contents), hashContents(contentsNew), func(w io.Writer) error {
+			_, err := w.Write(contents)
+			return err
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	r.staged[relPath] = file
+
+	return true, r.stageInjected(tx, file, func(w io.Writer) error {
+		_, err := w.Write(contentsNew)
+		return err
+	})
+}
+
+// Returns every file changed by `ReplaceOccurrences` so far, by the path it is
+// served from (without a compression extension, see `Reactenv.Compressed`)
+func (r *Reactenv) changedFiles() map[string]*File {
+	changed := make(map[string]*File, len(r.staged))
+	for relPath, file := range r.staged {
+		if file.Compression != CompressionNone {
+			_, relPath = CompressionFromPath(relPath)
+		}
+		changed[relPath] = file
+	}
+	return changed
+}
+
+// Opens the staged contents of a file (decompressed, if needed)
+func (r *Reactenv) openStaged(tx *Transaction, file *File) (io.ReadCloser, error) {
+	staged, err := tx.Open(r.OutPath(file))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decompress(staged, file.Compression)
+}
+
+// Reads the staged contents of a file (decompressed, if needed)
+func (r *Reactenv) readStaged(tx *Transaction, file *File) ([]byte, error) {
+	reader, err := r.openStaged(tx, file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// Returns the path (relative to `Reactenv.Dir`) of every regular file in `Dir`
+// with a name that matches `match` (regardless of `Reactenv.FileMatchers`)
+func (r *Reactenv) findFilesByName(match func(name string) bool) ([]string, error) {
+	relPaths := make([]string, 0)
+
+	err := filepath.WalkDir(r.Dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if r.isTemplateDir(filePath) || r.isOutDir(filePath) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.Type().IsRegular() || !match(entry.Name()) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(r.Dir, filePath)
+
+		if err != nil {
+			return err
+		}
+
+		relPaths = append(relPaths, filepath.ToSlash(relPath))
+		return nil
+	})
+
+	return relPaths, err
+}
@@ -1,31 +1,94 @@
+// Package reactenv injects environment variables into `__reactenv.<name>`
+// placeholders, in the files of a built app.
+//
+// Every step returns an error (nothing is printed, and the process never
+// exits), and takes a context that can be cancelled:
+//
+//	renv := reactenv.NewReactenv()
+//	if err := renv.FindFiles(ctx, "dist", nil); err != nil {
+//		return err
+//	}
+//	if err := renv.FindOccurrences(ctx); err != nil {
+//		return err
+//	}
+//	if err := renv.ReplaceOccurrences(ctx); err != nil {
+//		var missing *reactenv.MissingKeysError
+//		if errors.As(err, &missing) {
+//			// missing.Keys
+//		}
+//		return err
+//	}
 package reactenv
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/fs"
-	"os"
+	"net/url"
 	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
-
-	"github.com/hmerritt/reactenv/ui"
+	"sync"
 )
 
 const (
-	REACTENV_PREFIX          = "__reactenv"
-	REACTENV_FIND_EXPRESSION = `(__reactenv\.[a-zA-Z_$][0-9a-zA-Z_$]*)`
+	REACTENV_PREFIX      = "__reactenv"
+	REACTENV_IGNORE_FILE = ".reactenvignore"
+
+	// `__reactenv.<name>`, followed by an optional `:<type>`, and either `?` (optional) or `[<default>]`.
+	//
+	// Files are scanned without regex (see `ScanOccurrences`), this expression matches the same grammar.
+	REACTENV_FIND_EXPRESSION = `(__reactenv\.([a-zA-Z_$][0-9a-zA-Z_$]*)(?::(string|bool|number|json)\b)?(?:(\?)|\[([^\]"'` + "`" + `\\\r\n]*)\])?)`
 )
 
-type Reactenv struct {
-	UI *ui.Ui
+// Default file matchers, used when none are specified
+var REACTENV_FILE_MATCH_EXPRESSIONS_DEFAULT = []string{`^.+\.js$`}
+
+// Matches a comma-separated list of file extensions, e.g. `.js,.mjs,.cjs`
+var fileExtensionListExpression = regexp.MustCompile(`^\.[0-9A-Za-z_-]+(\s*,\s*\.[0-9A-Za-z_-]+)*$`)
 
+type Reactenv struct {
 	// Path of directory to scan
 	Dir string
+	// Maximum directory depth to scan, where `1` only scans `Dir` itself (`0` is unlimited)
+	MaxDepth int
+	// Glob patterns a file must match (at least one) to be scanned. Empty matches all files.
+	Include []string
+	// Glob patterns for files and directories to skip (in addition to `REACTENV_IGNORE_FILE`)
+	Exclude []string
+	// Compiled file matchers, a file name must match at least one to be scanned
+	FileMatchers []*regexp.Regexp
+	// Where placeholder values come from (defaults to the host environment, see `ChainSource` to combine sources)
+	Values ValueSource
+	// Keep the template (original contents) of every injected file, so they can be injected again
+	Templates bool
+	// Directory where templates are kept (defaults to `REACTENV_TEMPLATE_DIR` within `Dir`)
+	TemplateDir string
+	// Write a copy of `Dir` (with injected files) to this directory, leaving `Dir` untouched
+	OutDir string
+	// Also inject into precompressed assets (e.g. `main.js.gz`) that have no uncompressed file
+	Compressed bool
+	// Write the hashes of every inline script and style (after injection) to this file, as CSP directives
+	CSPHeaderFile string
+	// Number of files processed at once (defaults to the number of CPUs)
+	Concurrency int
+	// Files larger than this (in bytes) are streamed, rather than read into memory.
+	// `0` uses `REACTENV_STREAM_THRESHOLD`, and a negative value never streams.
+	StreamThreshold int64
+
+	templateManifest *TemplateManifest
+	// Files staged by `ReplaceOccurrences` (injected, or changed after injection), by path
+	staged map[string]*File
+	// Guards `templateManifest` when files are processed in parallel
+	mu sync.Mutex
 
 	// Total file count (that match `REACTENV_FIND_EXPRESSION`, within `Dir`)
 	FilesMatchTotal int
 	// Files with occurrences (not every matched file will have an occurrence, so this may be less than `FilesMatchTotal`)
-	Files []*fs.DirEntry
+	Files []*File
 
 	// Total individual occurrences count
 	OccurrencesTotal int
@@ -33,51 +96,271 @@ type Reactenv struct {
 	OccurrencesByFile []*FileOccurrences
 	// Map of all unique environment variable keys
 	OccurrenceKeys OccurrenceKeys
+	// Map of keys that must be set (used at least once without a default, or optional marker)
+	OccurrenceKeysRequired OccurrenceKeys
+	// Map of keys that have a default value (in at least one occurrence)
+	OccurrenceKeysDefault OccurrenceKeys
 	// Map of all environment variable key values (keys will be replaced with these values)
 	OccurrenceKeysReplacement OccurrenceKeysReplacement
+	// Occurrences that can not be injected (e.g. a value that is not valid for its type)
+	OccurrenceErrors []*OccurrenceError
+
+	// Source maps adjusted by `ReplaceOccurrences` (relative to `Dir`)
+	SourceMaps []string
+	// HTML files with Content-Security-Policy hashes updated by `ReplaceOccurrences` (relative to `Dir`)
+	CSPFiles []string
+	// HTML files with `integrity` attributes updated by `ReplaceOccurrences` (relative to `Dir`)
+	IntegrityFiles []string
+	// Service workers with precache revisions updated by `ReplaceOccurrences` (relative to `Dir`)
+	PrecacheFiles []string
+	// Problems found by `ReplaceOccurrences` that did not stop injection
+	Warnings []string
 }
 
+type File = struct {
+	// Slash-separated path, relative to `Reactenv.Dir`
+	Path  string
+	Entry fs.DirEntry
+	// Decides how values are escaped when injected into this file
+	Syntax Syntax
+	// Compression of a precompressed asset with no uncompressed file (see `Reactenv.Compressed`)
+	Compression Compression
+	// Contents kept by `FindOccurrences` (template contents, if re-injecting)
+	contents []byte
+	// File is too large to be read into memory, and is streamed instead
+	stream bool
+}
 type Occurrence = struct {
 	Key      string
 	StartEnd []int
+	// Syntax at the occurrence, which is the syntax of the file, except within
+	// inline `<script>` and `<style>` elements of HTML files
+	Syntax Syntax
+	// Literal enclosing the occurrence (always `LiteralNone` unless `Syntax` is JS or JSON)
+	Literal Literal
+	// Type annotation, values of a literal type are injected as JS literals
+	Type ValueType
+	// Occurrence is the entire literal, and the enclosing quotes will be replaced along with it
+	Unquote bool
+	// A typed value would join the code before (or after) it, and is separated by a space (see `separateLiteral`)
+	PadStart bool
+	PadEnd   bool
+	// Marked as optional (`__reactenv.<name>?`), and is left empty when not set
+	Optional bool
+	// Default value (`__reactenv.<name>[<default>]`), used when not set
+	Default    string
+	HasDefault bool
 }
 type OccurrenceKeys = map[string]bool
 type OccurrenceKeysReplacement = map[string]string
 type FileOccurrences = struct {
 	Occurrences []Occurrence
 }
+type OccurrenceError = struct {
+	File       *File
+	Occurrence Occurrence
+	Err        error
+}
 
-func NewReactenv(ui *ui.Ui) *Reactenv {
+func NewReactenv() *Reactenv {
 	return &Reactenv{
-		UI:                        ui,
 		Dir:                       "",
-		Files:                     make([]*fs.DirEntry, 0),
+		Values:                    EnvSource{},
+		Files:                     make([]*File, 0),
 		OccurrencesTotal:          0,
 		OccurrencesByFile:         make([]*FileOccurrences, 0),
 		OccurrenceKeys:            make(OccurrenceKeys),
+		OccurrenceKeysRequired:    make(OccurrenceKeys),
+		OccurrenceKeysDefault:     make(OccurrenceKeys),
 		OccurrenceKeysReplacement: make(OccurrenceKeysReplacement),
 	}
 }
 
-// Populates `Reactenv.Files` with all files that match `fileMatchExpression`
-func (r *Reactenv) FindFiles(dir string, fileMatchExpression string) error {
+// Converts a file matcher into a regular expression.
+//
+// Matchers are either a regular expression, or a comma-separated list
+// of file extensions (`.js,.mjs`) which is converted into an anchored expression.
+func FileMatchExpression(matcher string) string {
+	matcher = strings.TrimSpace(matcher)
+
+	if !fileExtensionListExpression.MatchString(matcher) {
+		return matcher
+	}
+
+	extensions := strings.Split(matcher, ",")
+	for i, extension := range extensions {
+		extensions[i] = regexp.QuoteMeta(strings.TrimPrefix(strings.TrimSpace(extension), "."))
+	}
+
+	if len(extensions) == 1 {
+		return fmt.Sprintf(`^.+\.%s$`, extensions[0])
+	}
+
+	return fmt.Sprintf(`^.+\.(?:%s)$`, strings.Join(extensions, "|"))
+}
+
+// Compiles file matchers (see `FileMatchExpression`).
+//
+// Uses `REACTENV_FILE_MATCH_EXPRESSIONS_DEFAULT` when `matchers` is empty.
+func CompileFileMatchers(matchers []string) ([]*regexp.Regexp, error) {
+	if len(matchers) == 0 {
+		matchers = REACTENV_FILE_MATCH_EXPRESSIONS_DEFAULT
+	}
+
+	fileMatchers := make([]*regexp.Regexp, 0, len(matchers))
+
+	for _, matcher := range matchers {
+		fileMatcher, err := regexp.Compile(FileMatchExpression(matcher))
+
+		if err != nil {
+			return nil, fmt.Errorf("file match expression '%s' is not valid: %w", matcher, err)
+		}
+
+		fileMatchers = append(fileMatchers, fileMatcher)
+	}
+
+	return fileMatchers, nil
+}
+
+// Returns the value of an environment variable, from `Reactenv.Values`
+func (r *Reactenv) LookupEnv(key string) (string, bool) {
+	if r.Values == nil {
+		return "", false
+	}
+	return r.Values.Lookup(key)
+}
+
+// Populates `Reactenv.Files` with all files that match at least one of `fileMatchExpressions`.
+//
+// Walks `dir` recursively (up to `Reactenv.MaxDepth`), skipping anything ignored
+// by `REACTENV_IGNORE_FILE` or `Reactenv.Exclude`.
+func (r *Reactenv) FindFiles(ctx context.Context, dir string, fileMatchExpressions []string) error {
 	r.Dir = dir
-	files, err := os.ReadDir(r.Dir)
+
+	fileMatchers, err := CompileFileMatchers(fileMatchExpressions)
 
 	if err != nil {
 		return err
 	}
 
-	fileMatcher, err := regexp.Compile(fileMatchExpression)
+	r.FileMatchers = fileMatchers
+
+	for _, pattern := range append(append([]string{}, r.Include...), r.Exclude...) {
+		if err := ValidateGlob(pattern); err != nil {
+			return fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+		}
+	}
+
+	if err := r.loadTemplates(); err != nil {
+		return fmt.Errorf("unable to load templates: %w", err)
+	}
+
+	ignoreRules, err := ReadIgnoreFile(filepath.Join(r.Dir, REACTENV_IGNORE_FILE))
 
 	if err != nil {
+		return fmt.Errorf("unable to read '%s': %w", REACTENV_IGNORE_FILE, err)
+	}
+
+	for _, pattern := range r.Exclude {
+		if rule, ok := ParseIgnoreRule(pattern); ok {
+			rule.Negate = false
+			ignoreRules = append(ignoreRules, rule)
+		}
+	}
+
+	// Index (in `r.Files`) of every real path found, so symlinks to the same file are only injected once
+	realPaths := make(map[string]int)
+
+	// Symlinked directories are not walked, so only `r.Dir` and symlinked files need resolving
+	realDir, err := resolveSymlink(r.Dir)
+
+	if err != nil {
+		return err
+	}
+
+	if realDir, err = filepath.Abs(realDir); err != nil {
 		return err
 	}
 
-	for _, file := range files {
-		if fileMatcher.MatchString(file.Name()) && !file.IsDir() {
-			r.Files = append(r.Files, &file)
+	err = filepath.WalkDir(r.Dir, func(filePath string, file fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(r.Dir, filePath)
+
+		if err != nil {
+			return err
 		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		relPath = filepath.ToSlash(relPath)
+		depth := strings.Count(relPath, "/") + 1
+
+		if file.IsDir() {
+			if r.isTemplateDir(filePath) || r.isOutDir(filePath) || MatchIgnoreRules(ignoreRules, relPath, true) || (r.MaxDepth > 0 && depth >= r.MaxDepth) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// Path of the uncompressed file (for precompressed assets)
+		compression, plainRelPath := CompressionNone, relPath
+
+		if r.Compressed {
+			compression, plainRelPath = r.compressedOnly(filePath, relPath)
+		}
+
+		if !r.isFileMatch(path.Base(plainRelPath)) || MatchIgnoreRules(ignoreRules, relPath, false) {
+			return nil
+		}
+
+		if len(r.Include) > 0 && !r.isIncluded(relPath) && !r.isIncluded(plainRelPath) {
+			return nil
+		}
+
+		realPath := filepath.Join(realDir, filepath.FromSlash(relPath))
+
+		if file.Type()&fs.ModeSymlink != 0 {
+			if realPath, err = resolveSymlink(filePath); err != nil {
+				return err
+			}
+
+			if realPath, err = filepath.Abs(realPath); err != nil {
+				return err
+			}
+		}
+
+		found := &File{
+			Path:        relPath,
+			Entry:       file,
+			Syntax:      SyntaxFromPath(plainRelPath),
+			Compression: compression,
+		}
+
+		if fileIndex, ok := realPaths[realPath]; ok {
+			// Prefer the file itself over a symlink to it
+			if r.Files[fileIndex].Entry.Type()&fs.ModeSymlink != 0 && file.Type()&fs.ModeSymlink == 0 {
+				r.Files[fileIndex] = found
+			}
+			return nil
+		}
+
+		realPaths[realPath] = len(r.Files)
+		r.Files = append(r.Files, found)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
 	}
 
 	r.FilesMatchTotal = len(r.Files)
@@ -85,11 +368,42 @@ func (r *Reactenv) FindFiles(dir string, fileMatchExpression string) error {
 	return nil
 }
 
+func (r *Reactenv) isFileMatch(fileName string) bool {
+	for _, fileMatcher := range r.FileMatchers {
+		if fileMatcher.MatchString(fileName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the expression of every file matcher in use
+func (r *Reactenv) FileMatchExpressions() []string {
+	expressions := make([]string, 0, len(r.FileMatchers))
+	for _, fileMatcher := range r.FileMatchers {
+		expressions = append(expressions, fileMatcher.String())
+	}
+	return expressions
+}
+
+func (r *Reactenv) isIncluded(relPath string) bool {
+	for _, pattern := range r.Include {
+		if MatchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the full path of a File (joined with `Reactenv.Dir`)
+func (r *Reactenv) FilePath(file *File) string {
+	return filepath.Join(r.Dir, filepath.FromSlash(file.Path))
+}
+
 // Run a callback for each File
-func (r *Reactenv) FilesWalk(fileCb func(fileIndex int, file fs.DirEntry, filePath string) error) error {
+func (r *Reactenv) FilesWalk(fileCb func(fileIndex int, file *File, filePath string) error) error {
 	for fileIndex, file := range r.Files {
-		filePath := path.Join(r.Dir, (*file).Name())
-		err := fileCb(fileIndex, *file, filePath)
+		err := fileCb(fileIndex, file, r.FilePath(file))
 		if err != nil {
 			return err
 		}
@@ -98,19 +412,25 @@ func (r *Reactenv) FilesWalk(fileCb func(fileIndex int, file fs.DirEntry, filePa
 	return nil
 }
 
+// Returns the contents of a File, kept by `FindOccurrences` (or read, if not kept)
+func (r *Reactenv) fileContents(file *File) ([]byte, error) {
+	if file.contents != nil {
+		return file.contents, nil
+	}
+	return r.ReadFile(file)
+}
+
 // Run a callback for each File, passing in the file contents
-func (r *Reactenv) FilesWalkContents(fileCb func(fileIndex int, file fs.DirEntry, filePath string, fileContents []byte) error) error {
+func (r *Reactenv) FilesWalkContents(fileCb func(fileIndex int, file *File, filePath string, fileContents []byte) error) error {
 	for fileIndex, file := range r.Files {
-		filePath := path.Join(r.Dir, (*file).Name())
-		fileContents, err := os.ReadFile(filePath)
+		filePath := r.FilePath(file)
+		fileContents, err := r.fileContents(file)
 
 		if err != nil {
-			r.UI.Error(fmt.Sprintf("Error when reading file '%s'.\n", (*file).Name()))
-			r.UI.Error(fmt.Sprintf("%v", err))
-			os.Exit(1)
+			return &FileError{Path: file.Path, Op: "read", Err: err}
 		}
 
-		err = fileCb(fileIndex, *file, filePath, fileContents)
+		err = fileCb(fileIndex, file, filePath, fileContents)
 
 		if err != nil {
 			return err
@@ -121,86 +441,353 @@ func (r *Reactenv) FilesWalkContents(fileCb func(fileIndex int, file fs.DirEntry
 }
 
 // Walks every file and populates `Reactenv.Occurrences*` fields.
-func (r *Reactenv) FindOccurrences() {
+//
+// Files are read (once) and scanned in parallel. The contents of files with
+// occurrences are kept, so `ReplaceOccurrences` does not need to read them again.
+//
+// Values that are missing, or not valid for their type, are not returned as
+// errors here (see `MissingKeys` and `Reactenv.OccurrenceErrors`).
+func (r *Reactenv) FindOccurrences(ctx context.Context) error {
 	// Reset occurrence fields
 	r.OccurrencesTotal = 0
 	r.OccurrencesByFile = make([]*FileOccurrences, 0)
 	r.OccurrenceKeys = make(OccurrenceKeys)
+	r.OccurrenceKeysRequired = make(OccurrenceKeys)
+	r.OccurrenceKeysDefault = make(OccurrenceKeys)
 	r.OccurrenceKeysReplacement = make(OccurrenceKeysReplacement)
+	r.OccurrenceErrors = make([]*OccurrenceError, 0)
+
+	fileOccurrences := make([][]Occurrence, len(r.Files))
+
+	err := r.FilesWalkParallel(ctx, func(fileIndex int, file *File) error {
+		stream, err := r.isStreamed(file)
+
+		if err != nil {
+			return &FileError{Path: file.Path, Op: "read", Err: err}
+		}
+
+		if stream {
+			file.stream = true
+			fileOccurrences[fileIndex], err = r.scanStream(file)
+			return err
+		}
+
+		fileContents, err := r.ReadFile(file)
+
+		if err != nil {
+			return &FileError{Path: file.Path, Op: "read", Err: err}
+		}
+
+		fileOccurrences[fileIndex] = ScanOccurrences(fileContents, file.Syntax)
+
+		if len(fileOccurrences[fileIndex]) > 0 {
+			file.contents = fileContents
+		}
 
-	// Prep for removing files with no occurrences
-	newFiles := make([]*fs.DirEntry, 0, len(r.Files))
-	newOccurrencesByFile := make([]*FileOccurrences, 0)
-	fileIndexesToRemove := make(map[int]int, 0)
+		return nil
+	})
 
-	r.FilesWalkContents(func(fileIndex int, file fs.DirEntry, filePath string, fileContents []byte) error {
-		fileOccurrences := regexp.MustCompile(REACTENV_FIND_EXPRESSION).FindAllIndex(fileContents, -1)
+	if err != nil {
+		return err
+	}
+
+	// Remove files with no occurrences
+	newFiles := make([]*File, 0, len(r.Files))
+
+	for fileIndex, file := range r.Files {
+		if len(fileOccurrences[fileIndex]) == 0 {
+			continue
+		}
 
-		fileOccurrencesToStore := make([]Occurrence, 0, len(fileOccurrences))
-		r.OccurrencesTotal += len(fileOccurrences)
+		newFiles = append(newFiles, file)
+		r.OccurrencesTotal += len(fileOccurrences[fileIndex])
 		r.OccurrencesByFile = append(r.OccurrencesByFile, &FileOccurrences{
-			Occurrences: fileOccurrencesToStore,
+			Occurrences: fileOccurrences[fileIndex],
 		})
 
-		for _, occurrence := range fileOccurrences {
-			occurrenceText := string(fileContents[occurrence[0]:occurrence[1]])
-			envName := strings.Replace(occurrenceText, "__reactenv.", "", 1)
-			envValue, envExists := os.LookupEnv(envName)
+		for _, occurrence := range fileOccurrences[fileIndex] {
+			r.addOccurrence(file, occurrence)
+		}
+	}
+
+	r.Files = newFiles
+
+	return nil
+}
+
+// Resolves the value of an occurrence, and adds it to the `Reactenv.OccurrenceKeys*` fields
+func (r *Reactenv) addOccurrence(file *File, occurrence Occurrence) {
+	envName := occurrence.Key
+	envValue, envExists := r.LookupEnv(envName)
 
-			r.OccurrencesByFile[fileIndex].Occurrences = append(r.OccurrencesByFile[fileIndex].Occurrences, Occurrence{
-				Key:      envName,
-				StartEnd: occurrence,
+	if IsLiteralType(occurrence.Type) && occurrence.Literal != LiteralNone && !occurrence.Unquote {
+		r.OccurrenceErrors = append(r.OccurrenceErrors, &OccurrenceError{
+			File:       file,
+			Occurrence: occurrence,
+			Err:        fmt.Errorf("a ':%s' value can not be injected into part of a string, the placeholder must be the entire string", occurrence.Type),
+		})
+	} else if envExists {
+		if _, err := FormatTypedValue(envValue, occurrence.Type); err != nil {
+			r.OccurrenceErrors = append(r.OccurrenceErrors, &OccurrenceError{
+				File:       file,
+				Occurrence: occurrence,
+				Err:        err,
+			})
+		}
+	} else if occurrence.HasDefault {
+		if _, err := FormatTypedValue(occurrence.Default, occurrence.Type); err != nil {
+			r.OccurrenceErrors = append(r.OccurrenceErrors, &OccurrenceError{
+				File:       file,
+				Occurrence: occurrence,
+				Err:        fmt.Errorf("default %w", err),
 			})
+		}
+	}
 
-			r.OccurrenceKeys[envName] = true
+	r.OccurrenceKeys[envName] = true
 
-			if envExists {
-				r.OccurrenceKeysReplacement[envName] = envValue
-			}
+	if occurrence.HasDefault {
+		r.OccurrenceKeysDefault[envName] = true
+	} else if !occurrence.Optional {
+		r.OccurrenceKeysRequired[envName] = true
+	}
+
+	if envExists {
+		r.OccurrenceKeysReplacement[envName] = envValue
+	}
+}
+
+// Default values are percent-decoded (so they can contain `]`, e.g. `%5D`).
+// Values that are not valid percent-encoding are used as-is.
+func decodeDefaultValue(value string) string {
+	decoded, err := url.PathUnescape(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// Returns all unique keys, sorted
+func (r *Reactenv) OccurrenceKeysSorted() []string {
+	keys := make([]string, 0, len(r.OccurrenceKeys))
+	for key := range r.OccurrenceKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns all required keys that have no value, sorted
+func (r *Reactenv) MissingKeys() []string {
+	keys := make([]string, 0)
+	for _, key := range r.OccurrenceKeysSorted() {
+		if _, ok := r.OccurrenceKeysReplacement[key]; !ok && r.OccurrenceKeysRequired[key] {
+			keys = append(keys, key)
 		}
+	}
+	return keys
+}
 
-		if len(fileOccurrences) == 0 {
-			fileIndexesToRemove[fileIndex] = fileIndex
+// Returns the start and end of the bytes replaced by an occurrence (including quotes, when unquoted)
+func OccurrenceSpan(occurrence Occurrence) (int, int) {
+	if occurrence.Unquote {
+		return occurrence.StartEnd[0] - 1, occurrence.StartEnd[1] + 1
+	}
+	return occurrence.StartEnd[0], occurrence.StartEnd[1]
+}
+
+// Returns the value an occurrence is replaced with (escaped, or formatted as a literal)
+func (r *Reactenv) OccurrenceValue(file *File, occurrence Occurrence) (string, error) {
+	envValue, envExists := r.OccurrenceKeysReplacement[occurrence.Key]
+
+	if !envExists && occurrence.HasDefault {
+		envValue = occurrence.Default
+	} else if !envExists && !occurrence.Optional {
+		return "", fmt.Errorf("'%s' is not set", occurrence.Key)
+	}
+
+	// Optional typed values that are not set are left `undefined` (or `null` in JSON)
+	if !envExists && !occurrence.HasDefault && IsLiteralType(occurrence.Type) {
+		switch occurrence.Syntax {
+		case SyntaxJS:
+			return separateLiteral(occurrence, "undefined"), nil
+		case SyntaxJSON:
+			return separateLiteral(occurrence, "null"), nil
 		}
+		return "", nil
+	}
 
-		return nil
-	})
+	if !IsLiteralType(occurrence.Type) {
+		return EscapeValue(envValue, occurrence.Syntax, occurrence.Literal), nil
+	}
 
-	// Remove files with no occurrences
-	if len(fileIndexesToRemove) > 0 {
-		for fileIndex, file := range r.Files {
-			if _, ok := fileIndexesToRemove[fileIndex]; !ok {
-				newFiles = append(newFiles, file)
-				newOccurrencesByFile = append(newOccurrencesByFile, r.OccurrencesByFile[fileIndex])
-			}
+	if occurrence.Literal != LiteralNone && !occurrence.Unquote {
+		return "", fmt.Errorf("'%s' is a ':%s' value, and can not be injected into part of a string", occurrence.Key, occurrence.Type)
+	}
+
+	literalValue, err := FormatTypedValue(envValue, occurrence.Type)
+
+	if err != nil {
+		return "", fmt.Errorf("'%s' %w", occurrence.Key, err)
+	}
+
+	// Literals within HTML (outside of an inline script) are escaped as any other value
+	if !hasLiterals(occurrence.Syntax) {
+		return EscapeValue(literalValue, occurrence.Syntax, LiteralNone), nil
+	}
+
+	return separateLiteral(occurrence, literalValue), nil
+}
+
+// Separates a typed value from the code around it, so it is parsed as the same
+// literal. Negative numbers, objects and arrays are wrapped in parentheses (in
+// JS), and the value is padded with spaces where it would join the code either
+// side (see `Occurrence.PadStart`).
+//
+// For example, `return"__reactenv.F:bool"` is injected as `return true` (not
+// `returntrue`), `y-"__reactenv.N:number"` as `y- (-5)` (not `y--5`), and
+// `()=>"__reactenv.J:json"` as `()=>({"a":1})` (not a function body).
+func separateLiteral(occurrence Occurrence, literalValue string) string {
+	if occurrence.Syntax == SyntaxJS && literalValue != "" && strings.ContainsRune("-{[", rune(literalValue[0])) {
+		literalValue = "(" + literalValue + ")"
+	}
+	if occurrence.PadStart {
+		literalValue = " " + literalValue
+	}
+	if occurrence.PadEnd {
+		literalValue += " "
+	}
+	return literalValue
+}
+
+// Returns `fileContents` with every occurrence replaced with its value
+func (r *Reactenv) RenderContents(file *File, fileContents []byte, occurrences []Occurrence) ([]byte, error) {
+	fileContentsNew := make([]byte, 0, len(fileContents))
+
+	lastIndex := 0
+	for _, occurrence := range occurrences {
+		start, end := OccurrenceSpan(occurrence)
+		envValue, err := r.OccurrenceValue(file, occurrence)
+
+		if err != nil {
+			return nil, err
 		}
 
-		r.Files = newFiles
-		r.OccurrencesByFile = newOccurrencesByFile
+		fileContentsNew = append(fileContentsNew, fileContents[lastIndex:start]...)
+		fileContentsNew = append(fileContentsNew, envValue...)
+		lastIndex = end
 	}
+	fileContentsNew = append(fileContentsNew, fileContents[lastIndex:]...)
+
+	return fileContentsNew, nil
 }
 
-func (r *Reactenv) ReplaceOccurrences() {
-	r.FilesWalkContents(func(fileIndex int, file fs.DirEntry, filePath string, fileContents []byte) error {
-		fileContentsNew := make([]byte, 0, len(fileContents))
+// Replaces every occurrence, in every file.
+//
+// Files are rendered and staged in parallel, then all written as a single
+// transaction (see `Transaction`). If any file fails to be written, or `ctx`
+// is cancelled, then every file is restored to its original contents.
+//
+// If `Reactenv.OutDir` is set, a copy of `Dir` is written there instead (see
+// `stageOutDir`), and templates are not kept.
+//
+// Source maps of injected files are adjusted to match (see `stageSourceMaps`),
+// as are Content-Security-Policy hashes of inline scripts and styles (see
+// `stageCSP`), the `integrity` attributes of HTML files (see `stageIntegrity`)
+// and service worker precache revisions (see `stagePrecache`).
+//
+// Nothing is written if any values are missing (`*MissingKeysError`), or can
+// not be injected (`*InvalidOccurrencesError`).
+func (r *Reactenv) ReplaceOccurrences(ctx context.Context) error {
+	if missingKeys := r.MissingKeys(); len(missingKeys) > 0 {
+		return &MissingKeysError{Keys: missingKeys}
+	}
+
+	if len(r.OccurrenceErrors) > 0 {
+		return &InvalidOccurrencesError{Occurrences: r.OccurrenceErrors}
+	}
+
+	tx := NewTransaction(ctx)
+
+	r.Warnings = make([]string, 0)
+	r.staged = make(map[string]*File, len(r.Files))
+	for _, file := range r.Files {
+		r.staged[file.Path] = file
+	}
+
+	if r.OutDir != "" {
+		if err := r.validateOutDir(); err != nil {
+			return err
+		}
 
-		lastIndex := 0
-		for _, occurrence := range r.OccurrencesByFile[fileIndex].Occurrences {
-			start, end := occurrence.StartEnd[0], occurrence.StartEnd[1]
-			envValue := r.OccurrenceKeysReplacement[occurrence.Key]
+		if err := r.stageOutDir(ctx, tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
 
-			fileContentsNew = append(fileContentsNew, fileContents[lastIndex:start]...)
-			fileContentsNew = append(fileContentsNew, envValue...)
-			lastIndex = end
+	err := r.FilesWalkParallel(ctx, func(fileIndex int, file *File) error {
+		if file.stream {
+			return r.replaceStream(tx, file, r.OccurrencesByFile[fileIndex].Occurrences)
 		}
-		fileContentsNew = append(fileContentsNew, fileContents[lastIndex:]...)
 
-		if err := os.WriteFile(filePath, fileContentsNew, 0644); err != nil {
-			r.UI.Error(fmt.Sprintf("Error when writing to file '%s'.\n", filePath))
-			r.UI.Error(fmt.Sprintf("%v", err))
-			os.Exit(1)
+		fileContents, err := r.fileContents(file)
+
+		if err != nil {
+			return &FileError{Path: file.Path, Op: "read", Err: err}
+		}
+
+		fileContentsNew, err := r.RenderContents(file, fileContents, r.OccurrencesByFile[fileIndex].Occurrences)
+
+		if err != nil {
+			return &FileError{Path: file.Path, Op: "inject into", Err: err}
+		}
+
+		if r.keepTemplates() {
+			if err := r.stageTemplate(tx, file, hashContents(fileContents), hashContents(fileContentsNew), func(w io.Writer) error {
+				_, err := w.Write(fileContents)
+				return err
+			}); err != nil {
+				return &FileError{Path: file.Path, Op: "keep template of", Err: err}
+			}
+		}
+
+		err = r.stageInjected(tx, file, func(w io.Writer) error {
+			_, err := w.Write(fileContentsNew)
+			return err
+		})
+
+		if err != nil {
+			return &FileError{Path: file.Path, Op: "write", Err: err}
 		}
 
 		return nil
 	})
+
+	if err == nil {
+		r.SourceMaps, err = r.stageSourceMaps(tx)
+	}
+
+	if err == nil {
+		r.CSPFiles, err = r.stageCSP(tx)
+	}
+
+	if err == nil {
+		r.IntegrityFiles, err = r.stageIntegrity(tx)
+	}
+
+	if err == nil {
+		r.PrecacheFiles, err = r.stagePrecache(tx)
+	}
+
+	if err == nil && r.keepTemplates() {
+		err = r.stageTemplateManifest(tx)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
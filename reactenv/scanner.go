@@ -0,0 +1,264 @@
+package reactenv
+
+import (
+	"bytes"
+	"strings"
+)
+
+var placeholderPrefix = []byte(REACTENV_PREFIX + ".")
+
+// Placeholder type annotations (without the `:`)
+var placeholderTypes = []ValueType{TypeString, TypeBool, TypeNumber, TypeJSON}
+
+func isNameStartByte(b byte) bool {
+	return b == '_' || b == '$' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isNameByte(b byte) bool {
+	return isNameStartByte(b) || (b >= '0' && b <= '9')
+}
+
+// Matches regex `\w` (used as a word boundary after a type annotation)
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// Bytes that can not be part of a default value
+func isDefaultStopByte(b byte) bool {
+	return b == ']' || b == '"' || b == '\'' || b == '`' || b == '\\' || b == '\r' || b == '\n'
+}
+
+// Parses a placeholder starting at `contents[start]` (which must begin with `placeholderPrefix`).
+//
+// Implements the same grammar as `REACTENV_FIND_EXPRESSION`, returns the
+// occurrence (without `Literal` or `Unquote`) and whether it is valid.
+//
+// When `atEOF` is false, `contents` may be followed by more bytes. If the
+// placeholder could continue past the end of `contents`, `more` is returned
+// (and the placeholder should be parsed again once more bytes are read).
+func parsePlaceholder(contents []byte, start int, atEOF bool) (occurrence Occurrence, ok bool, more bool) {
+	i := start + len(placeholderPrefix)
+
+	if i >= len(contents) {
+		return Occurrence{}, false, !atEOF
+	}
+
+	if !isNameStartByte(contents[i]) {
+		return Occurrence{}, false, false
+	}
+
+	nameStart := i
+	for i < len(contents) && isNameByte(contents[i]) {
+		i++
+	}
+
+	occurrence.Key = string(contents[nameStart:i])
+
+	// `:<type>`, followed by a word boundary
+	if i < len(contents) && contents[i] == ':' {
+		for _, valueType := range placeholderTypes {
+			end := i + 1 + len(valueType)
+			if end > len(contents) {
+				if !atEOF && bytes.HasPrefix([]byte(valueType), contents[i+1:]) {
+					return Occurrence{}, false, true
+				}
+				continue
+			}
+			if string(contents[i+1:end]) == valueType && (end == len(contents) || !isWordByte(contents[end])) {
+				occurrence.Type = valueType
+				i = end
+				break
+			}
+		}
+	}
+
+	// `?` or `[<default>]`
+	if i < len(contents) && contents[i] == '?' {
+		occurrence.Optional = true
+		i++
+	} else if i < len(contents) && contents[i] == '[' {
+		end := i + 1
+		for end < len(contents) && !isDefaultStopByte(contents[end]) {
+			end++
+		}
+		if end == len(contents) && !atEOF {
+			return Occurrence{}, false, true
+		}
+		if end < len(contents) && contents[end] == ']' {
+			occurrence.Default = decodeDefaultValue(string(contents[i+1 : end]))
+			occurrence.HasDefault = true
+			i = end + 1
+		}
+	}
+
+	// The byte after a placeholder decides if it is complete (and if it is a whole literal)
+	if i == len(contents) && !atEOF {
+		return Occurrence{}, false, true
+	}
+
+	occurrence.StartEnd = []int{start, i}
+
+	return occurrence, true, false
+}
+
+// Reports whether a literal injected next to `b` would join it (e.g. `return` and
+// `true`, or `-` and `-5`), and must be separated by a space
+func isLiteralJoinByte(b byte) bool {
+	return isIdentifierByte(b) || b == '+' || b == '-' || b == '.'
+}
+
+// Finds placeholders in contents that may be read in chunks (see `ScanOccurrencesReader`)
+type occurrenceScanner struct {
+	syntax Syntax
+	lexer  *jsLexer
+	// Offset (within the whole file) of the first byte passed to `scan`
+	base int
+	// Offset (within the whole file) the lexer has been fed up to
+	lexerIndex int
+	// Parse the next placeholder as if at EOF, even if it could continue
+	force bool
+
+	occurrences []Occurrence
+}
+
+func newOccurrenceScanner(syntax Syntax) *occurrenceScanner {
+	return &occurrenceScanner{
+		syntax:      syntax,
+		lexer:       &jsLexer{},
+		occurrences: make([]Occurrence, 0),
+	}
+}
+
+// Finds every placeholder in `contents` (which starts at `s.base`).
+//
+// Returns the number of bytes that have been scanned, which can be passed to
+// `drop`. The rest (e.g. a placeholder cut short by the end of a chunk) must
+// be scanned again, along with the next chunk.
+func (s *occurrenceScanner) scan(contents []byte, atEOF bool) int {
+	offset := 0
+
+	for offset < len(contents) {
+		index := bytes.Index(contents[offset:], placeholderPrefix)
+
+		if index < 0 {
+			if atEOF {
+				return len(contents)
+			}
+			// Keep enough bytes to find a prefix split across chunks
+			return max(offset, len(contents)-len(placeholderPrefix)+1)
+		}
+
+		start := offset + index
+		force := atEOF || s.force
+		occurrence, ok, more := parsePlaceholder(contents, start, force)
+		s.force = false
+
+		if more {
+			return start
+		}
+
+		if !ok {
+			offset = start + 1
+			continue
+		}
+
+		end := occurrence.StartEnd[1]
+
+		occurrence.Syntax = s.syntax
+
+		if hasLiterals(s.syntax) {
+			s.lexer.write(contents[s.lexerIndex-s.base : start])
+			s.lexerIndex = s.base + start
+			occurrence.Literal = s.lexer.literal()
+
+			if IsLiteralType(occurrence.Type) && occurrence.Literal != LiteralNone {
+				occurrence.Unquote = end < len(contents) && s.lexer.isWholeLiteral(contents[end])
+			}
+
+			if IsLiteralType(occurrence.Type) && (occurrence.Literal == LiteralNone || occurrence.Unquote) {
+				// Bytes either side of the injected literal (outside the quotes, when unquoted)
+				before, next := s.lexer.recent[1], end
+				if occurrence.Unquote {
+					before, next = s.lexer.recent[0], end+1
+				}
+
+				if next >= len(contents) && !force {
+					return start
+				}
+
+				occurrence.PadStart = isLiteralJoinByte(before)
+				occurrence.PadEnd = next < len(contents) && isLiteralJoinByte(contents[next])
+			}
+		}
+
+		occurrence.StartEnd = []int{s.base + start, s.base + end}
+		s.occurrences = append(s.occurrences, occurrence)
+		offset = end
+	}
+
+	return offset
+}
+
+// Drops the first `n` bytes of `contents` (returned by `scan`), feeding them to the lexer first
+func (s *occurrenceScanner) drop(contents []byte, n int) {
+	if hasLiterals(s.syntax) && s.lexerIndex < s.base+n {
+		s.lexer.write(contents[s.lexerIndex-s.base : n])
+		s.lexerIndex = s.base + n
+	}
+	s.base += n
+}
+
+// Finds every placeholder in `contents`, in a single pass (without regex).
+//
+// For `SyntaxJS` and `SyntaxJSON` files, the enclosing literal of each
+// occurrence is also found (see `jsLexer`). HTML files are scanned as JS
+// within inline scripts (see `scanHTMLOccurrences`).
+func ScanOccurrences(contents []byte, syntax Syntax) []Occurrence {
+	if syntax == SyntaxHTML {
+		return scanHTMLOccurrences(contents)
+	}
+	return scanRange(contents, 0, len(contents), syntax)
+}
+
+// Finds every placeholder in `contents[start:end]`, as `syntax`
+func scanRange(contents []byte, start int, end int, syntax Syntax) []Occurrence {
+	s := newOccurrenceScanner(syntax)
+	s.base, s.lexerIndex = start, start
+	s.scan(contents[start:end], true)
+	return s.occurrences
+}
+
+// Finds every placeholder in an HTML document. The contents of inline
+// `<script>` elements are scanned as JS (or JSON, e.g. `type="application/json"`),
+// and of `<style>` elements as CSS, so values can not close the element.
+func scanHTMLOccurrences(contents []byte) []Occurrence {
+	occurrences := make([]Occurrence, 0)
+	lastIndex := 0
+
+	for _, tag := range scanHTMLTags(contents) {
+		if tag.contentStart < 0 {
+			continue
+		}
+
+		occurrences = append(occurrences, scanRange(contents, lastIndex, tag.contentStart, SyntaxHTML)...)
+		occurrences = append(occurrences, scanRange(contents, tag.contentStart, tag.contentEnd, elementSyntax(tag))...)
+		lastIndex = tag.contentEnd
+	}
+
+	return append(occurrences, scanRange(contents, lastIndex, len(contents), SyntaxHTML)...)
+}
+
+// Returns the syntax of the contents of a `<script>` or `<style>` element
+func elementSyntax(tag htmlTag) Syntax {
+	if tag.name == "style" {
+		return SyntaxCSS
+	}
+
+	scriptType, _ := tag.attr("type")
+	switch contentType := strings.ToLower(strings.TrimSpace(scriptType.value)); {
+	case contentType == "importmap", contentType == "speculationrules", strings.HasSuffix(contentType, "json"):
+		return SyntaxJSON
+	}
+
+	return SyntaxJS
+}
@@ -0,0 +1,349 @@
+package reactenv
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestScanOccurrencesGrammar(t *testing.T) {
+	tests := []struct {
+		contents string
+		want     []Occurrence
+	}{
+		{`__reactenv.A`, []Occurrence{{Key: "A", StartEnd: []int{0, 12}}}},
+		{`x=__reactenv.$a_1+__reactenv._B`, []Occurrence{{Key: "$a_1", StartEnd: []int{2, 17}}, {Key: "_B", StartEnd: []int{18, 31}}}},
+		{`__reactenv.1A __reactenv. __reactenv`, []Occurrence{}},
+		{`__reactenv.A:bool`, []Occurrence{{Key: "A", Type: TypeBool, StartEnd: []int{0, 17}}}},
+		{`__reactenv.A:number?`, []Occurrence{{Key: "A", Type: TypeNumber, Optional: true, StartEnd: []int{0, 20}}}},
+		{`__reactenv.A:json[{}]`, []Occurrence{{Key: "A", Type: TypeJSON, Default: "{}", HasDefault: true, StartEnd: []int{0, 21}}}},
+		// Type annotations must end at a word boundary
+		{`__reactenv.A:booly`, []Occurrence{{Key: "A", StartEnd: []int{0, 12}}}},
+		{`__reactenv.A:other`, []Occurrence{{Key: "A", StartEnd: []int{0, 12}}}},
+		{`__reactenv.A?`, []Occurrence{{Key: "A", Optional: true, StartEnd: []int{0, 13}}}},
+		{`__reactenv.A[x y]`, []Occurrence{{Key: "A", Default: "x y", HasDefault: true, StartEnd: []int{0, 17}}}},
+		{`__reactenv.A[]`, []Occurrence{{Key: "A", Default: "", HasDefault: true, StartEnd: []int{0, 14}}}},
+		// Defaults are percent-decoded
+		{`__reactenv.A[a%5Db%20]`, []Occurrence{{Key: "A", Default: "a]b ", HasDefault: true, StartEnd: []int{0, 22}}}},
+		{`__reactenv.A[100%]`, []Occurrence{{Key: "A", Default: "100%", HasDefault: true, StartEnd: []int{0, 18}}}},
+		// Defaults can not contain quotes, backslashes or line breaks
+		{`__reactenv.A[a"]`, []Occurrence{{Key: "A", StartEnd: []int{0, 12}}}},
+		{"__reactenv.A[a\n]", []Occurrence{{Key: "A", StartEnd: []int{0, 12}}}},
+		{`__reactenv.A[a`, []Occurrence{{Key: "A", StartEnd: []int{0, 12}}}},
+		{`__reactenv.__reactenv.A`, []Occurrence{{Key: "__reactenv", StartEnd: []int{0, 21}}}},
+	}
+
+	for _, test := range tests {
+		got := ScanOccurrences([]byte(test.contents), SyntaxRaw)
+
+		for i := range got {
+			got[i].Syntax = 0
+		}
+
+		if !reflect.DeepEqual(got, test.want) && !(len(got) == 0 && len(test.want) == 0) {
+			t.Errorf("ScanOccurrences(%q)\n got: %+v\nwant: %+v", test.contents, got, test.want)
+		}
+	}
+}
+
+// The byte scanner must find exactly what `REACTENV_FIND_EXPRESSION` matches
+func TestScanOccurrencesMatchesExpression(t *testing.T) {
+	expression := regexp.MustCompile(REACTENV_FIND_EXPRESSION)
+	random := rand.New(rand.NewSource(1))
+	pieces := []string{"__reactenv.", "__reactenv.A", "B", "_", "$", "1", ":", "bool", "number", "json", "string", "?", "[", "]", "%5D", `"`, "'", "`", `\`, "\n", " ", "x", "."}
+
+	for i := 0; i < 20000; i++ {
+		var contents strings.Builder
+		for n := random.Intn(12); n >= 0; n-- {
+			contents.WriteString(pieces[random.Intn(len(pieces))])
+		}
+
+		want := expression.FindAllIndex([]byte(contents.String()), -1)
+		got := ScanOccurrences([]byte(contents.String()), SyntaxRaw)
+
+		spans := make([][]int, 0, len(got))
+		for _, occurrence := range got {
+			spans = append(spans, occurrence.StartEnd)
+		}
+
+		if len(want) == 0 && len(spans) == 0 {
+			continue
+		}
+
+		if !reflect.DeepEqual(spans, want) {
+			t.Fatalf("ScanOccurrences(%q) found %v, expression matches %v", contents.String(), spans, want)
+		}
+	}
+}
+
+func TestScanOccurrencesLiterals(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		// Literal of each occurrence
+		want []Literal
+	}{
+		{"none", `a=__reactenv.A`, []Literal{LiteralNone}},
+		{"double quote", `a="__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+		{"single quote", `a='x __reactenv.A'`, []Literal{LiteralSingleQuote}},
+		{"template", "a=`x __reactenv.A`", []Literal{LiteralTemplate}},
+		{"after a string", `a="x",b=__reactenv.A`, []Literal{LiteralNone}},
+
+		// Escaped quotes do not close a string
+		{"escaped double quote", `a="\"__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+		{"escaped single quote", `a='it\'s __reactenv.A'`, []Literal{LiteralSingleQuote}},
+		{"escaped backslash", `a="\\",b=__reactenv.A`, []Literal{LiteralNone}},
+		{"other quotes", `a="'` + "`" + `__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+
+		// Templates with `${}` expressions
+		{"template expression", "a=`${b}__reactenv.A`", []Literal{LiteralTemplate}},
+		{"in template expression", "a=`${__reactenv.A}`", []Literal{LiteralNone}},
+		{"string in template expression", "a=`${\"__reactenv.A\"}`", []Literal{LiteralDoubleQuote}},
+		{"braces in template expression", "a=`${{b:1}.b}__reactenv.A`", []Literal{LiteralTemplate}},
+		{"nested template", "a=`${`${b}`}__reactenv.A`,c=__reactenv.B", []Literal{LiteralTemplate, LiteralNone}},
+		{"escaped dollar", "a=`\\${__reactenv.A}`", []Literal{LiteralTemplate}},
+		{"dollar without brace", "a=`$__reactenv.A`", []Literal{LiteralTemplate}},
+
+		// Regex or division
+		{"regex", `a=/"/.test(b),c="__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+		{"regex after keyword", `return/"/.source+"__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+		{"regex class", `a=/[/"]/,b="__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+		{"regex escape", `a=/\/"/,b="__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+		{"division", `a=b/2+"__reactenv.A"+c/2`, []Literal{LiteralDoubleQuote}},
+		{"division after paren", `a=(b)/2,c="__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+		{"division after bracket", `a=b[0]/2/"__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+
+		// Comments
+		{"line comment", "// \"\na=\"__reactenv.A\"", []Literal{LiteralDoubleQuote}},
+		{"block comment", `/* " */a="__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+		{"block comment with stars", `/** " **/a="__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+		{"in comment", `/* __reactenv.A */`, []Literal{LiteralNone}},
+		{"quote in string is not a comment", `a="//",b="__reactenv.A"`, []Literal{LiteralDoubleQuote}},
+	}
+
+	for _, test := range tests {
+		occurrences := ScanOccurrences([]byte(test.contents), SyntaxJS)
+		got := make([]Literal, 0, len(occurrences))
+		for _, occurrence := range occurrences {
+			got = append(got, occurrence.Literal)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: ScanOccurrences(%q) literals = %v, want %v", test.name, test.contents, got, test.want)
+		}
+
+		// The lexer is fed in chunks when streaming
+		for size := 1; size < len(test.contents); size++ {
+			chunked, err := ScanOccurrencesReader(&chunkReader{contents: []byte(test.contents), size: size}, SyntaxJS)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(chunked, occurrences) {
+				t.Errorf("%s: chunks of %d found %+v, want %+v", test.name, size, chunked, occurrences)
+			}
+		}
+	}
+}
+
+func TestScanOccurrencesUnquote(t *testing.T) {
+	tests := []struct {
+		contents string
+		want     bool
+	}{
+		{`a="__reactenv.A:bool"`, true},
+		{`a='__reactenv.A:number'`, true},
+		{"a=`__reactenv.A:json`", true},
+		{`a="__reactenv.A:bool?"`, true},
+		{`a="__reactenv.A:bool[true]"`, true},
+		{`a="x__reactenv.A:bool"`, false},
+		{`a="__reactenv.A:bool "`, false},
+		{`a=__reactenv.A:bool`, false},
+		{`a="__reactenv.A"`, false},
+		{`a="__reactenv.A:string"`, false},
+	}
+
+	for _, test := range tests {
+		occurrences := ScanOccurrences([]byte(test.contents), SyntaxJS)
+		if len(occurrences) != 1 || occurrences[0].Unquote != test.want {
+			t.Errorf("ScanOccurrences(%q) = %+v, want Unquote %v", test.contents, occurrences, test.want)
+		}
+	}
+}
+
+// Generates a minified bundle of about `size` bytes, with strings, templates,
+// regexes and comments, and a placeholder every `every` bytes (none if `every` is 0)
+func generateBundle(random *rand.Rand, size int, every int) []byte {
+	pieces := []string{
+		`function a(b,c){return b/c}`,
+		`var d="some string with \"escaped\" quotes";`,
+		"const e=`template ${d} literal`;",
+		`if(/[a-z]+\/"/.test(d)){d=d.replace(/"/g,"'")}`,
+		`/* block comment */`,
+		`e=(d.length)/2;`,
+		`f={g:'single',h:[1,2,3],i:!0};`,
+		"\n// line comment\n",
+	}
+	placeholders := []string{`"__reactenv.API_URL"`, `"__reactenv.FEATURE:bool"`, "`/api/__reactenv.VERSION`", `"__reactenv.RETRIES:number[3]"`}
+
+	var bundle strings.Builder
+	next := every
+	for bundle.Len() < size {
+		bundle.WriteString(pieces[random.Intn(len(pieces))])
+		if every > 0 && bundle.Len() >= next {
+			bundle.WriteString("x=" + placeholders[random.Intn(len(placeholders))] + ";")
+			next += every
+		}
+	}
+	return []byte(bundle.String())
+}
+
+// Generates `count` bundles of `size` bytes. As in most builds, only some of
+// them (one in `every`) contain placeholders.
+func generateBundles(count int, size int, every int) [][]byte {
+	random := rand.New(rand.NewSource(1))
+	bundles := make([][]byte, count)
+	for i := range bundles {
+		placeholderEvery := 0
+		if i%every == 0 {
+			placeholderEvery = 4 << 10
+		}
+		bundles[i] = generateBundle(random, size, placeholderEvery)
+	}
+	return bundles
+}
+
+// Writes `bundles` to a temporary directory (in chunk directories), and returns it
+func writeBundles(b *testing.B, bundles [][]byte) string {
+	b.Helper()
+
+	dir := b.TempDir()
+
+	for i, bundle := range bundles {
+		filePath := filepath.Join(dir, fmt.Sprintf("chunk-%d", i/50), fmt.Sprintf("%d.js", i))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, bundle, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// Finds every occurrence the way reactenv did before `ScanOccurrences`: files
+// are read one at a time, and the expression is compiled for every file
+func findOccurrencesRegexp(dir string) (int, error) {
+	total := 0
+
+	err := filepath.WalkDir(dir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(filePath, ".js") {
+			return err
+		}
+
+		fileContents, err := os.ReadFile(filePath)
+
+		if err != nil {
+			return err
+		}
+
+		total += len(regexp.MustCompile(REACTENV_FIND_EXPRESSION).FindAllIndex(fileContents, -1))
+		return nil
+	})
+
+	return total, err
+}
+
+func BenchmarkScanOccurrences(b *testing.B) {
+	fixtures := []struct {
+		name    string
+		bundles [][]byte
+	}{
+		// One in ten bundles contain placeholders
+		{"bundles", generateBundles(200, 64<<10, 10)},
+		// Every bundle contains placeholders (so every byte is lexed)
+		{"dense", generateBundles(50, 64<<10, 1)},
+	}
+
+	for _, fixture := range fixtures {
+		size := 0
+		for _, bundle := range fixture.bundles {
+			size += len(bundle)
+		}
+
+		b.Run(fixture.name+"/regexp", func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				for _, bundle := range fixture.bundles {
+					regexp.MustCompile(REACTENV_FIND_EXPRESSION).FindAllIndex(bundle, -1)
+				}
+			}
+		})
+
+		// Finds the same spans as the expression
+		b.Run(fixture.name+"/scanner", func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				for _, bundle := range fixture.bundles {
+					ScanOccurrences(bundle, SyntaxRaw)
+				}
+			}
+		})
+
+		// Also finds the enclosing literal of each occurrence
+		b.Run(fixture.name+"/scanner-js", func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				for _, bundle := range fixture.bundles {
+					ScanOccurrences(bundle, SyntaxJS)
+				}
+			}
+		})
+	}
+}
+
+// Finds every occurrence in a directory of bundles, as `reactenv run` does
+func BenchmarkFindOccurrences(b *testing.B) {
+	// Many small chunks, as in a large app
+	dir := writeBundles(b, generateBundles(2000, 16<<10, 10))
+
+	want, err := findOccurrencesRegexp(dir)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("regexp", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := findOccurrencesRegexp(dir); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pipeline", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			renv := NewReactenv()
+
+			if err := renv.FindFiles(context.Background(), dir, nil); err != nil {
+				b.Fatal(err)
+			}
+
+			if err := renv.FindOccurrences(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+
+			if renv.OccurrencesTotal != want {
+				b.Fatalf("found %d occurrences, want %d", renv.OccurrencesTotal, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package reactenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Provides values for placeholders (see `Reactenv.Values`)
+type ValueSource interface {
+	// Returns the value of `key`, and whether it is set
+	Lookup(key string) (string, bool)
+	// Returns every key with a value
+	List() []string
+}
+
+// Host environment variables
+type EnvSource struct{}
+
+func (EnvSource) Lookup(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
+
+func (EnvSource) List() []string {
+	keys := make([]string, 0)
+	for _, entry := range os.Environ() {
+		if key, _, ok := strings.Cut(entry, "="); ok && key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Fixed set of values, e.g. loaded from a file
+type MapSource map[string]string
+
+func (m MapSource) Lookup(key string) (string, bool) {
+	value, ok := m[key]
+	return value, ok
+}
+
+func (m MapSource) List() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Sources in order of precedence, the first source with a value for a key wins
+type ChainSource []ValueSource
+
+func (c ChainSource) Lookup(key string) (string, bool) {
+	for _, source := range c {
+		if value, ok := source.Lookup(key); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Returns every key with a value (in any source), sorted
+func (c ChainSource) List() []string {
+	unique := make(map[string]bool)
+	for _, source := range c {
+		for _, key := range source.List() {
+			unique[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(unique))
+	for key := range unique {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the source of values used by commands. Values are resolved in this
+// order (highest precedence first):
+//  1. Host environment variables
+//  2. `.env` files (see `NewEnvFileSource`, the last file wins)
+//  3. JSON files (see `NewJSONFileSource`, the last file wins)
+func NewValueSource(envFilePaths []string, jsonFilePaths []string) (ChainSource, error) {
+	envFileSource, err := NewEnvFileSource(envFilePaths...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	jsonFileSource, err := NewJSONFileSource(jsonFilePaths...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ChainSource{EnvSource{}, envFileSource, jsonFileSource}, nil
+}
+
+// Loads values from `.env` files (see `ParseEnv`), later files take precedence.
+//
+// Variables within files are expanded using the host environment first.
+func NewEnvFileSource(filePaths ...string) (MapSource, error) {
+	values := make(MapSource)
+	for _, filePath := range filePaths {
+		if err := ParseEnvFile(filePath, values, os.LookupEnv); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Loads values from JSON files, each containing a single object. Later files take precedence.
+//
+// Strings are used as-is, numbers and booleans as they are written, and
+// objects and arrays as (compact) JSON, e.g. for `:json` placeholders.
+// Keys with a `null` value are not set.
+func NewJSONFileSource(filePaths ...string) (MapSource, error) {
+	values := make(MapSource)
+	for _, filePath := range filePaths {
+		contents, err := os.ReadFile(filePath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := parseJSONValues(contents, values); err != nil {
+			return nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+	return values, nil
+}
+
+func parseJSONValues(contents []byte, values MapSource) error {
+	if trimmed := bytes.TrimSpace(contents); len(trimmed) == 0 || trimmed[0] != '{' {
+		return errors.New("expected a JSON object")
+	}
+
+	object := make(map[string]json.RawMessage)
+
+	if err := json.Unmarshal(contents, &object); err != nil {
+		return err
+	}
+
+	for key, raw := range object {
+		var value interface{}
+
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("invalid value for '%s': %w", key, err)
+		}
+
+		switch v := value.(type) {
+		case nil:
+			delete(values, key)
+		case string:
+			values[key] = v
+		case json.Number:
+			values[key] = v.String()
+		case bool:
+			values[key] = fmt.Sprint(v)
+		default:
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, raw); err != nil {
+				return fmt.Errorf("invalid value for '%s': %w", key, err)
+			}
+			values[key] = compact.String()
+		}
+	}
+
+	return nil
+}
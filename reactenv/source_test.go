@@ -0,0 +1,168 @@
+package reactenv
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestChainSource(t *testing.T) {
+	chain := ChainSource{
+		MapSource{"A": "first", "B": ""},
+		MapSource{"A": "second", "B": "second", "C": "second"},
+		MapSource{"D": "third", "A": "third"},
+	}
+
+	tests := []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		{"A", "first", true},
+		// Empty values are set, so take precedence
+		{"B", "", true},
+		{"C", "second", true},
+		{"D", "third", true},
+		{"E", "", false},
+	}
+
+	for _, test := range tests {
+		if value, ok := chain.Lookup(test.key); value != test.value || ok != test.ok {
+			t.Errorf("Lookup(%q) = %q, %v, want %q, %v", test.key, value, ok, test.value, test.ok)
+		}
+	}
+
+	// Every key once, sorted
+	if keys := chain.List(); !reflect.DeepEqual(keys, []string{"A", "B", "C", "D"}) {
+		t.Errorf("List() = %v, want [A B C D]", keys)
+	}
+
+	if keys := (ChainSource{}).List(); len(keys) != 0 {
+		t.Errorf("List() of an empty chain = %v, want none", keys)
+	}
+}
+
+func TestParseJSONValues(t *testing.T) {
+	values := MapSource{"NULL": "set before", "KEPT": "kept"}
+
+	err := parseJSONValues([]byte(`{
+		"STRING": "a \"b\"",
+		"EMPTY": "",
+		"INT": 42,
+		"FLOAT": 1.50,
+		"BIG": 12345678901234567890,
+		"BOOL": false,
+		"NULL": null,
+		"OBJECT": { "a": [1, 2], "b": { "c": null } },
+		"ARRAY": [ "a", 1 ]
+	}`), values)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := MapSource{
+		"KEPT":   "kept",
+		"STRING": `a "b"`,
+		"EMPTY":  "",
+		// Numbers are kept as they are written (not rounded by float64)
+		"INT":   "42",
+		"FLOAT": "1.50",
+		"BIG":   "12345678901234567890",
+		"BOOL":  "false",
+		// Nested values are compact JSON
+		"OBJECT": `{"a":[1,2],"b":{"c":null}}`,
+		"ARRAY":  `["a",1]`,
+	}
+
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("parseJSONValues = %v, want %v", values, want)
+	}
+
+	for _, contents := range []string{``, `[]`, `"a"`, `{"a":}`, `{"a":1} {"b":2}`, `null`} {
+		if err := parseJSONValues([]byte(contents), make(MapSource)); err == nil {
+			t.Errorf("parseJSONValues(%q) succeeded, want an error", contents)
+		}
+	}
+}
+
+// Later JSON files take precedence (and `null` unsets a value from an earlier file)
+func TestNewJSONFileSource(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.json": `{"A": "a", "B": "a", "C": "a"}`,
+		"b.json": `{"B": "b", "C": null}`,
+	})
+
+	values, err := NewJSONFileSource(filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (MapSource{"A": "a", "B": "b"}); !reflect.DeepEqual(values, want) {
+		t.Errorf("NewJSONFileSource = %v, want %v", values, want)
+	}
+
+	if _, err := NewJSONFileSource(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("NewJSONFileSource of a missing file succeeded")
+	}
+}
+
+// Host environment variables take precedence over .env files, which take precedence over JSON files
+func TestNewValueSource(t *testing.T) {
+	dir := t.TempDir()
+
+	// Keys are prefixed, so they do not collide with the host environment
+	writeFiles(t, dir, map[string]string{
+		"a.env":  "REACTENV_TEST_HOST=env-file\nREACTENV_TEST_ENV=a\nREACTENV_TEST_BOTH=env-file\n",
+		"b.env":  "REACTENV_TEST_ENV=b\n",
+		"a.json": `{"REACTENV_TEST_HOST": "json", "REACTENV_TEST_BOTH": "json", "REACTENV_TEST_JSON": "a", "REACTENV_TEST_NULL": "a"}`,
+		"b.json": `{"REACTENV_TEST_JSON": "b", "REACTENV_TEST_NULL": null}`,
+	})
+
+	t.Setenv("REACTENV_TEST_HOST", "host")
+
+	values, err := NewValueSource(
+		[]string{filepath.Join(dir, "a.env"), filepath.Join(dir, "b.env")},
+		[]string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		{"REACTENV_TEST_HOST", "host", true},
+		{"REACTENV_TEST_BOTH", "env-file", true},
+		{"REACTENV_TEST_ENV", "b", true},
+		{"REACTENV_TEST_JSON", "b", true},
+		{"REACTENV_TEST_NULL", "", false},
+	}
+
+	for _, test := range tests {
+		if value, ok := values.Lookup(test.key); value != test.value || ok != test.ok {
+			t.Errorf("Lookup(%q) = %q, %v, want %q, %v", test.key, value, ok, test.value, test.ok)
+		}
+	}
+
+	keys := values.List()
+	for _, key := range []string{"REACTENV_TEST_HOST", "REACTENV_TEST_BOTH", "REACTENV_TEST_ENV", "REACTENV_TEST_JSON"} {
+		if !slices.Contains(keys, key) {
+			t.Errorf("List() does not contain %q", key)
+		}
+	}
+
+	if slices.Contains(keys, "REACTENV_TEST_NULL") || !slices.IsSorted(keys) {
+		t.Errorf("List() = %v, want every set key, sorted", keys)
+	}
+
+	if _, err := NewValueSource([]string{filepath.Join(dir, "missing.env")}, nil); err == nil {
+		t.Errorf("NewValueSource with a missing .env file succeeded")
+	}
+}
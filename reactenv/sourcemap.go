@@ -0,0 +1,439 @@
+package reactenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Matches a `sourceMappingURL` comment, e.g. `//# sourceMappingURL=main.js.map` (or `/*# ... */` in CSS)
+var sourceMappingURLExpression = regexp.MustCompile(`^\s*(?://|/\*)[#@]\s*sourceMappingURL=(\S+?)\s*(?:\*/)?\s*$`)
+
+// Lines longer than this can not be a `sourceMappingURL` comment (data URLs are not adjusted)
+const sourceMappingURLMaxLength = 4096
+
+// Position within a generated file. Columns are in UTF-16 code units, as in source maps.
+type sourcePosition struct {
+	line   int
+	column int
+}
+
+func (p sourcePosition) before(other sourcePosition) bool {
+	return p.line < other.line || (p.line == other.line && p.column < other.column)
+}
+
+// An occurrence that has been replaced, with its position before and after injection
+type sourceMapEdit struct {
+	start    sourcePosition
+	end      sourcePosition
+	newStart sourcePosition
+	newEnd   sourcePosition
+}
+
+// Maps positions in a file before injection to positions after it
+type sourceMapShift []sourceMapEdit
+
+// Returns the position of `p` after injection. Positions within a replaced
+// occurrence are moved to the start of its value.
+func (s sourceMapShift) position(p sourcePosition) sourcePosition {
+	i := sort.Search(len(s), func(i int) bool { return p.before(s[i].start) }) - 1
+
+	if i < 0 {
+		return p
+	}
+
+	edit := s[i]
+
+	if p.before(edit.end) {
+		return edit.newStart
+	}
+
+	if p.line == edit.end.line {
+		return sourcePosition{edit.newEnd.line, edit.newEnd.column + p.column - edit.end.column}
+	}
+
+	return sourcePosition{p.line + edit.newEnd.line - edit.end.line, p.column}
+}
+
+// Number of UTF-16 code units taken by a UTF-8 byte (continuation bytes take none)
+func utf16Units(b byte) int {
+	switch {
+	case b < 0x80:
+		return 1
+	case b < 0xC0:
+		return 0
+	case b < 0xF0:
+		return 1
+	}
+	return 2
+}
+
+// Returns the position after `value`, when written at `start`
+func positionAfter(start sourcePosition, value string) sourcePosition {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\n' {
+			start = sourcePosition{start.line + 1, 0}
+		} else {
+			start.column += utf16Units(value[i])
+		}
+	}
+	return start
+}
+
+// Reads a file (before injection), and returns the position of every replaced
+// occurrence, and its `sourceMappingURL` (the last one in the file).
+func (r *Reactenv) scanSourceMap(file *File, reader io.Reader, occurrences []Occurrence) (sourceMapShift, string, error) {
+	shift := make(sourceMapShift, 0, len(occurrences))
+	sourceMappingURL := ""
+
+	offsets := make([]int, 0, len(occurrences)*2)
+	for _, occurrence := range occurrences {
+		start, end := OccurrenceSpan(occurrence)
+		offsets = append(offsets, start, end)
+	}
+
+	position := sourcePosition{}
+	positions := make([]sourcePosition, 0, len(offsets))
+	line := make([]byte, 0, 256)
+
+	endLine := func() {
+		if match := sourceMappingURLExpression.FindSubmatch(line); match != nil {
+			sourceMappingURL = string(match[1])
+		}
+		line = line[:0]
+	}
+
+	chunk := make([]byte, REACTENV_STREAM_CHUNK_SIZE)
+	offset := 0
+	for {
+		n, err := reader.Read(chunk)
+
+		for _, b := range chunk[:n] {
+			for len(positions) < len(offsets) && offsets[len(positions)] == offset {
+				positions = append(positions, position)
+			}
+			offset++
+
+			if b == '\n' {
+				endLine()
+				position = sourcePosition{position.line + 1, 0}
+				continue
+			}
+
+			position.column += utf16Units(b)
+			if len(line) < sourceMappingURLMaxLength {
+				line = append(line, b)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	endLine()
+
+	for len(positions) < len(offsets) && offsets[len(positions)] == offset {
+		positions = append(positions, position)
+	}
+
+	if len(positions) < len(offsets) {
+		return nil, "", errors.New("file has changed since it was scanned")
+	}
+
+	for i, occurrence := range occurrences {
+		value, err := r.OccurrenceValue(file, occurrence)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		edit := sourceMapEdit{start: positions[i*2], end: positions[i*2+1]}
+		edit.newStart = shift.position(edit.start)
+		edit.newEnd = positionAfter(edit.newStart, value)
+		shift = append(shift, edit)
+	}
+
+	return shift, sourceMappingURL, nil
+}
+
+// Stages the source map of every injected file (found via `sourceMappingURL`),
+// with its `mappings` adjusted for values that are a different length to
+// their placeholder. Files without a source map (or with an inline, or remote
+// one) are skipped. Returns the path of every adjusted source map.
+func (r *Reactenv) stageSourceMaps(tx *Transaction) ([]string, error) {
+	adjusted := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for fileIndex, file := range r.Files {
+		mapRelPath, shift, err := r.findSourceMap(file, r.OccurrencesByFile[fileIndex].Occurrences)
+
+		if err != nil {
+			return nil, &FileError{Path: file.Path, Op: "read", Err: err}
+		}
+
+		// Each map is only adjusted for the first file that uses it
+		if mapRelPath == "" || seen[mapRelPath] {
+			continue
+		}
+
+		seen[mapRelPath] = true
+
+		ok, err := r.stageProcessed(tx, mapRelPath, func(contents []byte) ([]byte, error) {
+			return adjustSourceMap(contents, shift)
+		})
+
+		if err != nil {
+			return nil, &FileError{Path: mapRelPath, Op: "adjust source map", Err: err}
+		}
+
+		if ok {
+			adjusted = append(adjusted, mapRelPath)
+		}
+	}
+
+	return adjusted, nil
+}
+
+// Returns the source map of a file (if it exists), and how its positions move
+func (r *Reactenv) findSourceMap(file *File, occurrences []Occurrence) (string, sourceMapShift, error) {
+	var reader io.Reader
+
+	if file.stream {
+		fileReader, err := r.OpenFile(file)
+		if err != nil {
+			return "", nil, err
+		}
+		defer fileReader.Close()
+		reader = fileReader
+	} else {
+		fileContents, err := r.fileContents(file)
+		if err != nil {
+			return "", nil, err
+		}
+		reader = bytes.NewReader(fileContents)
+	}
+
+	shift, sourceMappingURL, err := r.scanSourceMap(file, reader, occurrences)
+
+	if err != nil || sourceMappingURL == "" {
+		return "", nil, err
+	}
+
+	mapRelPath, ok := resolveURL(file.Path, sourceMappingURL)
+
+	if !ok {
+		return "", nil, nil
+	}
+
+	info, err := os.Stat(filepath.Join(r.Dir, filepath.FromSlash(mapRelPath)))
+
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+		return "", nil, nil
+	}
+
+	return mapRelPath, shift, err
+}
+
+// Returns a source map with its `mappings` adjusted by `shift`. Everything
+// else in the map is left exactly as it is.
+func adjustSourceMap(contents []byte, shift sourceMapShift) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("expected a JSON object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if token != "mappings" {
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		start := decoder.InputOffset()
+		var mappings string
+
+		if err := decoder.Decode(&mappings); err != nil {
+			return nil, fmt.Errorf("invalid mappings: %w", err)
+		}
+
+		end := decoder.InputOffset()
+		start += int64(bytes.IndexByte(contents[start:end], '"'))
+
+		mappingsNew, err := adjustMappings(mappings, shift)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid mappings: %w", err)
+		}
+
+		if mappingsNew == mappings {
+			return contents, nil
+		}
+
+		value, err := json.Marshal(mappingsNew)
+
+		if err != nil {
+			return nil, err
+		}
+
+		contentsNew := make([]byte, 0, len(contents)+len(value)-int(end-start))
+		contentsNew = append(contentsNew, contents[:start]...)
+		contentsNew = append(contentsNew, value...)
+		contentsNew = append(contentsNew, contents[end:]...)
+		return contentsNew, nil
+	}
+
+	// No `mappings` (e.g. an index map with `sections`)
+	return contents, nil
+}
+
+// A decoded mapping segment, with absolute values
+type sourceMapSegment struct {
+	position sourcePosition
+	// Source, original line, original column and name (only the first `fields-1` are set)
+	values [4]int
+	fields int
+}
+
+// Decodes VLQ `mappings`, moves every generated position by `shift`, and encodes them again
+func adjustMappings(mappings string, shift sourceMapShift) (string, error) {
+	segments := make([]sourceMapSegment, 0, len(mappings)/4)
+	values := [4]int{}
+	lines := strings.Split(mappings, ";")
+
+	for lineIndex, line := range lines {
+		column := 0
+		for _, field := range strings.Split(line, ",") {
+			if field == "" {
+				continue
+			}
+
+			decoded, err := decodeVLQ(field)
+
+			if err != nil {
+				return "", err
+			}
+
+			if len(decoded) != 1 && len(decoded) != 4 && len(decoded) != 5 {
+				return "", fmt.Errorf("segment '%s' has %d fields", field, len(decoded))
+			}
+
+			column += decoded[0]
+			for i := 1; i < len(decoded); i++ {
+				values[i-1] += decoded[i]
+			}
+
+			segments = append(segments, sourceMapSegment{
+				position: shift.position(sourcePosition{lineIndex, column}),
+				values:   values,
+				fields:   len(decoded),
+			})
+		}
+	}
+
+	lineCount := shift.position(sourcePosition{len(lines) - 1, 0}).line + 1
+
+	var encoded strings.Builder
+	encoded.Grow(len(mappings))
+
+	line, column := 0, 0
+	previous := [4]int{}
+	for i, segment := range segments {
+		if i > 0 && segment.position.line == line {
+			encoded.WriteByte(',')
+		}
+		for ; line < segment.position.line; line++ {
+			encoded.WriteByte(';')
+			column = 0
+		}
+
+		encodeVLQ(&encoded, segment.position.column-column)
+		column = segment.position.column
+
+		for i := 0; i < segment.fields-1; i++ {
+			encodeVLQ(&encoded, segment.values[i]-previous[i])
+			previous[i] = segment.values[i]
+		}
+	}
+	for ; line < lineCount-1; line++ {
+		encoded.WriteByte(';')
+	}
+
+	return encoded.String(), nil
+}
+
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// Decodes the base64 VLQ values of a segment
+func decodeVLQ(field string) ([]int, error) {
+	values := make([]int, 0, 5)
+	value, shift := 0, 0
+
+	for i := 0; i < len(field); i++ {
+		digit := strings.IndexByte(base64Alphabet, field[i])
+
+		if digit < 0 {
+			r, _ := utf8.DecodeRuneInString(field[i:])
+			return nil, fmt.Errorf("invalid base64 character '%c'", r)
+		}
+
+		value += (digit & 31) << shift
+
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+
+		if value&1 != 0 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, shift = 0, 0
+	}
+
+	if shift != 0 {
+		return nil, fmt.Errorf("segment '%s' ends mid-value", field)
+	}
+
+	return values, nil
+}
+
+func encodeVLQ(b *strings.Builder, value int) {
+	vlq := value << 1
+	if value < 0 {
+		vlq = (-value << 1) | 1
+	}
+	for {
+		digit := vlq & 31
+		vlq >>= 5
+		if vlq > 0 {
+			digit |= 32
+		}
+		b.WriteByte(base64Alphabet[digit])
+		if vlq == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package reactenv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVLQ(t *testing.T) {
+	tests := []struct {
+		value   int
+		encoded string
+	}{
+		{0, "A"},
+		{1, "C"},
+		{-1, "D"},
+		{15, "e"},
+		{-15, "f"},
+		{16, "gB"},
+		{-16, "hB"},
+		{511, "+f"},
+		{512, "ggB"},
+		{1000, "w+B"},
+		{-1000, "x+B"},
+	}
+
+	for _, test := range tests {
+		var encoded strings.Builder
+		encodeVLQ(&encoded, test.value)
+
+		if encoded.String() != test.encoded {
+			t.Errorf("encodeVLQ(%d) = %q, want %q", test.value, encoded.String(), test.encoded)
+		}
+
+		if decoded, err := decodeVLQ(test.encoded); err != nil || !reflect.DeepEqual(decoded, []int{test.value}) {
+			t.Errorf("decodeVLQ(%q) = %v, %v, want [%d]", test.encoded, decoded, err, test.value)
+		}
+	}
+}
+
+func TestVLQRoundTrip(t *testing.T) {
+	values := []int{0, 3, -3, 31, -31, 32, -32, 1 << 10, -(1 << 10), 1<<31 - 1, -(1<<31 - 1)}
+
+	var encoded strings.Builder
+	for _, value := range values {
+		encodeVLQ(&encoded, value)
+	}
+
+	decoded, err := decodeVLQ(encoded.String())
+
+	if err != nil || !reflect.DeepEqual(decoded, values) {
+		t.Errorf("decodeVLQ(%q) = %v, %v, want %v", encoded.String(), decoded, err, values)
+	}
+}
+
+func TestDecodeVLQErrors(t *testing.T) {
+	for _, field := range []string{"A!", "AA=", "g", "AAg"} {
+		if decoded, err := decodeVLQ(field); err == nil {
+			t.Errorf("decodeVLQ(%q) = %v, want an error", field, decoded)
+		}
+	}
+}
+
+// Segment of a test mapping, with absolute values
+type testSegment struct {
+	column int
+	// Original line (in source 0, at original column 0)
+	line int
+}
+
+// Encodes the segments of each generated line as `mappings`
+func encodeMappings(lines [][]testSegment) string {
+	var encoded strings.Builder
+	previousLine := 0
+
+	for lineIndex, segments := range lines {
+		if lineIndex > 0 {
+			encoded.WriteByte(';')
+		}
+		column := 0
+		for i, segment := range segments {
+			if i > 0 {
+				encoded.WriteByte(',')
+			}
+			encodeVLQ(&encoded, segment.column-column)
+			encodeVLQ(&encoded, 0)
+			encodeVLQ(&encoded, segment.line-previousLine)
+			encodeVLQ(&encoded, 0)
+			column, previousLine = segment.column, segment.line
+		}
+	}
+
+	return encoded.String()
+}
+
+// Decodes `mappings` (encoded by `encodeMappings`) into the segments of each generated line
+func decodeMappings(t *testing.T, mappings string) [][]testSegment {
+	t.Helper()
+
+	lines := make([][]testSegment, 0)
+	previousLine := 0
+
+	for _, line := range strings.Split(mappings, ";") {
+		segments := make([]testSegment, 0)
+		column := 0
+		for _, field := range strings.Split(line, ",") {
+			if field == "" {
+				continue
+			}
+			decoded, err := decodeVLQ(field)
+			if err != nil || len(decoded) != 4 {
+				t.Fatalf("invalid segment %q in %q (%v)", field, mappings, err)
+			}
+			column += decoded[0]
+			previousLine += decoded[2]
+			segments = append(segments, testSegment{column, previousLine})
+		}
+		lines = append(lines, segments)
+	}
+
+	return lines
+}
+
+func TestAdjustMappings(t *testing.T) {
+	lines := [][]testSegment{
+		{{0, 0}, {10, 1}, {15, 2}, {22, 3}, {30, 4}},
+		{},
+		{{5, 5}, {9, 6}},
+	}
+
+	tests := []struct {
+		name  string
+		shift sourceMapShift
+		want  [][]testSegment
+	}{
+		{
+			"no edits",
+			sourceMapShift{},
+			lines,
+		},
+		{
+			// Segments within the placeholder move to the start of the value
+			"same length",
+			sourceMapShift{{start: sourcePosition{0, 10}, end: sourcePosition{0, 22}, newStart: sourcePosition{0, 10}, newEnd: sourcePosition{0, 22}}},
+			[][]testSegment{
+				{{0, 0}, {10, 1}, {10, 2}, {22, 3}, {30, 4}},
+				{},
+				{{5, 5}, {9, 6}},
+			},
+		},
+		{
+			"shorter",
+			sourceMapShift{{start: sourcePosition{0, 10}, end: sourcePosition{0, 22}, newStart: sourcePosition{0, 10}, newEnd: sourcePosition{0, 14}}},
+			[][]testSegment{
+				{{0, 0}, {10, 1}, {10, 2}, {14, 3}, {22, 4}},
+				{},
+				{{5, 5}, {9, 6}},
+			},
+		},
+		{
+			"longer",
+			sourceMapShift{{start: sourcePosition{0, 10}, end: sourcePosition{0, 22}, newStart: sourcePosition{0, 10}, newEnd: sourcePosition{0, 40}}},
+			[][]testSegment{
+				{{0, 0}, {10, 1}, {10, 2}, {40, 3}, {48, 4}},
+				{},
+				{{5, 5}, {9, 6}},
+			},
+		},
+		{
+			// Following lines move down
+			"multi-line value",
+			sourceMapShift{{start: sourcePosition{0, 10}, end: sourcePosition{0, 22}, newStart: sourcePosition{0, 10}, newEnd: sourcePosition{1, 3}}},
+			[][]testSegment{
+				{{0, 0}, {10, 1}, {10, 2}},
+				{{3, 3}, {11, 4}},
+				{},
+				{{5, 5}, {9, 6}},
+			},
+		},
+		{
+			// Earlier edits move the positions of later ones
+			"multiple edits",
+			sourceMapShift{
+				{start: sourcePosition{0, 10}, end: sourcePosition{0, 22}, newStart: sourcePosition{0, 10}, newEnd: sourcePosition{0, 12}},
+				{start: sourcePosition{2, 6}, end: sourcePosition{2, 9}, newStart: sourcePosition{2, 6}, newEnd: sourcePosition{2, 16}},
+			},
+			[][]testSegment{
+				{{0, 0}, {10, 1}, {10, 2}, {12, 3}, {20, 4}},
+				{},
+				{{5, 5}, {16, 6}},
+			},
+		},
+	}
+
+	mappings := encodeMappings(lines)
+
+	for _, test := range tests {
+		adjusted, err := adjustMappings(mappings, test.shift)
+
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if got := decodeMappings(t, adjusted); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: adjustMappings(%q) = %q\n got: %v\nwant: %v", test.name, mappings, adjusted, got, test.want)
+		}
+	}
+
+	// Unchanged positions are encoded exactly as they were
+	if adjusted, err := adjustMappings(mappings, sourceMapShift{}); err != nil || adjusted != mappings {
+		t.Errorf("adjustMappings without edits = %q, %v, want %q", adjusted, err, mappings)
+	}
+}
+
+func TestAdjustMappingsErrors(t *testing.T) {
+	for _, mappings := range []string{"AA", "AAAAAA", "A!AA", "AAAg"} {
+		if _, err := adjustMappings(mappings, sourceMapShift{}); err == nil {
+			t.Errorf("adjustMappings(%q) succeeded, want an error", mappings)
+		}
+	}
+}
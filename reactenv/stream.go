@@ -0,0 +1,191 @@
+package reactenv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+)
+
+const (
+	// Files larger than this (in bytes) are streamed, rather than read into memory
+	REACTENV_STREAM_THRESHOLD = 16 << 20
+	// Size of each chunk read when streaming
+	REACTENV_STREAM_CHUNK_SIZE = 64 << 10
+	// Maximum bytes buffered when streaming. A placeholder longer than this
+	// (e.g. an unterminated `[<default>`) is parsed as if the file ended.
+	REACTENV_STREAM_BUFFER_MAX = 1 << 20
+)
+
+// Reports whether a file should be streamed (see `Reactenv.StreamThreshold`)
+func (r *Reactenv) isStreamed(file *File) (bool, error) {
+	threshold := r.StreamThreshold
+	if threshold == 0 {
+		threshold = REACTENV_STREAM_THRESHOLD
+	}
+
+	// HTML files are scanned whole, to find their inline scripts (see `scanHTMLOccurrences`)
+	if threshold < 0 || file.Syntax == SyntaxHTML {
+		return false, nil
+	}
+
+	info, err := os.Stat(r.FilePath(file))
+
+	if err != nil {
+		return false, err
+	}
+
+	return info.Size() > threshold, nil
+}
+
+// Finds every placeholder read from `reader`, using a bounded buffer.
+//
+// Returns the same occurrences as `ScanOccurrences`, including placeholders
+// that span chunk boundaries. HTML documents are read whole.
+func ScanOccurrencesReader(reader io.Reader, syntax Syntax) ([]Occurrence, error) {
+	// HTML documents can not be scanned in chunks, as inline scripts are scanned differently
+	if syntax == SyntaxHTML {
+		contents, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		return ScanOccurrences(contents, syntax), nil
+	}
+
+	s := newOccurrenceScanner(syntax)
+	buf := make([]byte, 0, REACTENV_STREAM_CHUNK_SIZE)
+	atEOF := false
+
+	for {
+		// Grown explicitly, as `append` may round past `REACTENV_STREAM_BUFFER_MAX`
+		if len(buf) == cap(buf) && cap(buf) < REACTENV_STREAM_BUFFER_MAX {
+			grown := make([]byte, len(buf), min(2*cap(buf), REACTENV_STREAM_BUFFER_MAX))
+			copy(grown, buf)
+			buf = grown
+		}
+
+		// Buffer is full of a single placeholder, stop waiting for the rest of it
+		s.force = len(buf) == cap(buf)
+
+		if !s.force {
+			n, err := reader.Read(buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+n]
+
+			if errors.Is(err, io.EOF) {
+				atEOF = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		scanned := s.scan(buf, atEOF)
+
+		if atEOF {
+			return s.occurrences, nil
+		}
+
+		s.drop(buf, scanned)
+		buf = buf[:copy(buf, buf[scanned:])]
+	}
+}
+
+// Finds every placeholder in a file, without reading it into memory (see `ScanOccurrencesReader`)
+func (r *Reactenv) scanStream(file *File) ([]Occurrence, error) {
+	reader, err := r.OpenFile(file)
+
+	if err != nil {
+		return nil, &FileError{Path: file.Path, Op: "read", Err: err}
+	}
+
+	defer reader.Close()
+
+	occurrences, err := ScanOccurrencesReader(reader, file.Syntax)
+
+	if err != nil {
+		return nil, &FileError{Path: file.Path, Op: "read", Err: err}
+	}
+
+	return occurrences, nil
+}
+
+// Writes the contents read from `reader` to `writer`, with every occurrence replaced.
+//
+// Streaming version of `RenderContents`, only a small buffer is held in memory.
+func (r *Reactenv) RenderStream(file *File, reader io.Reader, writer io.Writer, occurrences []Occurrence) error {
+	lastIndex := 0
+	for _, occurrence := range occurrences {
+		start, end := OccurrenceSpan(occurrence)
+		envValue, err := r.OccurrenceValue(file, occurrence)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.CopyN(writer, reader, int64(start-lastIndex)); err != nil {
+			return changedWhileStreaming(err)
+		}
+
+		if _, err := io.CopyN(io.Discard, reader, int64(end-start)); err != nil {
+			return changedWhileStreaming(err)
+		}
+
+		if _, err := io.WriteString(writer, envValue); err != nil {
+			return err
+		}
+
+		lastIndex = end
+	}
+
+	_, err := io.Copy(writer, reader)
+	return err
+}
+
+func changedWhileStreaming(err error) error {
+	if errors.Is(err, io.EOF) {
+		return errors.New("file has changed since it was scanned")
+	}
+	return err
+}
+
+// Streams a file into the transaction, with every occurrence replaced (see `RenderStream`)
+func (r *Reactenv) replaceStream(tx *Transaction, file *File, occurrences []Occurrence) error {
+	reader, err := r.OpenFile(file)
+
+	if err != nil {
+		return &FileError{Path: file.Path, Op: "read", Err: err}
+	}
+
+	defer reader.Close()
+
+	templateHash := sha256.New()
+	renderedHash := sha256.New()
+
+	err = r.stageInjected(tx, file, func(w io.Writer) error {
+		return r.RenderStream(file, io.TeeReader(reader, templateHash), io.MultiWriter(w, renderedHash), occurrences)
+	})
+
+	if err != nil {
+		return &FileError{Path: file.Path, Op: "inject into", Err: err}
+	}
+
+	if !r.keepTemplates() {
+		return nil
+	}
+
+	err = r.stageTemplate(tx, file, hex.EncodeToString(templateHash.Sum(nil)), hex.EncodeToString(renderedHash.Sum(nil)), func(w io.Writer) error {
+		template, err := r.OpenFile(file)
+		if err != nil {
+			return err
+		}
+		defer template.Close()
+		_, err = io.Copy(w, template)
+		return err
+	})
+
+	if err != nil {
+		return &FileError{Path: file.Path, Op: "keep template of", Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package reactenv
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// Generates contents with placeholders (some cut short), literals, regexes and comments
+func generateContents(random *rand.Rand, pieces int) []byte {
+	parts := []string{
+		"__reactenv.", "__reactenv.A", "__reactenv.B_1", ":bool", ":number", ":json", ":string", "?", "[", "]", "[x%5Dy]",
+		`"`, "'", "`", `\`, "${", "}", "{", "/", "/*", "*/", "//", "\n", " ", "return", "x", "=", "(", ")", "-", "+", ".",
+	}
+
+	var contents strings.Builder
+	for i := 0; i < pieces; i++ {
+		contents.WriteString(parts[random.Intn(len(parts))])
+	}
+	return []byte(contents.String())
+}
+
+// Placeholders that span chunk boundaries must be found as if read whole
+func TestScanOccurrencesReaderChunks(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		contents := generateContents(random, random.Intn(200))
+
+		for _, syntax := range []Syntax{SyntaxRaw, SyntaxJS, SyntaxJSON} {
+			want := ScanOccurrences(contents, syntax)
+
+			readers := map[string]io.Reader{
+				"one byte":   iotest.OneByteReader(bytes.NewReader(contents)),
+				"half":       iotest.HalfReader(bytes.NewReader(contents)),
+				"data error": iotest.DataErrReader(bytes.NewReader(contents)),
+				"random":     &chunkReader{contents: contents, size: 1 + random.Intn(32)},
+			}
+
+			for name, reader := range readers {
+				got, err := ScanOccurrencesReader(reader, syntax)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("%s reader of %q (syntax %v)\n got: %+v\nwant: %+v", name, contents, syntax, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestScanOccurrencesReaderError(t *testing.T) {
+	reader := io.MultiReader(strings.NewReader(`a="__reactenv.A`), iotest.ErrReader(io.ErrUnexpectedEOF))
+
+	if _, err := ScanOccurrencesReader(reader, SyntaxJS); err != io.ErrUnexpectedEOF {
+		t.Errorf("ScanOccurrencesReader = %v, want the read error", err)
+	}
+}
+
+// A placeholder longer than `REACTENV_STREAM_BUFFER_MAX` is parsed as if the file ended
+func TestScanOccurrencesReaderBufferMax(t *testing.T) {
+	long := strings.Repeat("a", 2*REACTENV_STREAM_BUFFER_MAX)
+
+	tests := []struct {
+		name     string
+		contents string
+		want     []Occurrence
+	}{
+		{
+			// Same as `ScanOccurrences`, the default is never closed
+			"unterminated default",
+			`a="__reactenv.A[` + long + `",b="__reactenv.B"`,
+			ScanOccurrences([]byte(`a="__reactenv.A[`+long+`",b="__reactenv.B"`), SyntaxJS),
+		},
+		{
+			// The default is closed after the buffer is full
+			"long default",
+			`a="__reactenv.A[` + long + `]",b="__reactenv.B"`,
+			[]Occurrence{
+				{Key: "A", Syntax: SyntaxJS, Literal: LiteralDoubleQuote, StartEnd: []int{3, 15}},
+				{Key: "B", Syntax: SyntaxJS, Literal: LiteralDoubleQuote, StartEnd: []int{len(long) + 22, len(long) + 34}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := ScanOccurrencesReader(&chunkReader{contents: []byte(test.contents), size: REACTENV_STREAM_CHUNK_SIZE}, SyntaxJS)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: ScanOccurrencesReader found %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
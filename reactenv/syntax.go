@@ -0,0 +1,431 @@
+package reactenv
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// Syntax of a file, decides how values are escaped when injected
+type Syntax int
+
+const (
+	// Values are injected as-is (CSS, and any other files)
+	SyntaxRaw Syntax = iota
+	// Values are escaped to match the enclosing JS literal
+	SyntaxJS
+	// Values are escaped as JSON strings
+	SyntaxJSON
+	// Values are HTML escaped. Within inline `<script>` elements they are
+	// escaped as JS (or JSON), and within `<style>` elements as `SyntaxCSS`.
+	SyntaxHTML
+	// Contents of an inline `<style>` element, where quotes and `<` are escaped
+	// (so the value can not close the element). Only used for occurrences within HTML.
+	SyntaxCSS
+)
+
+// Kind of literal that encloses an occurrence
+type Literal int
+
+const (
+	// Occurrence is not within a literal (or file syntax is `SyntaxRaw`)
+	LiteralNone Literal = iota
+	// "..."
+	LiteralDoubleQuote
+	// '...'
+	LiteralSingleQuote
+	// `...`
+	LiteralTemplate
+)
+
+// Opening/closing quote of each literal
+var literalQuotes = map[Literal]byte{
+	LiteralDoubleQuote: '"',
+	LiteralSingleQuote: '\'',
+	LiteralTemplate:    '`',
+}
+
+var syntaxByExtension = map[string]Syntax{
+	".js":   SyntaxJS,
+	".mjs":  SyntaxJS,
+	".cjs":  SyntaxJS,
+	".jsx":  SyntaxJS,
+	".ts":   SyntaxJS,
+	".tsx":  SyntaxJS,
+	".json": SyntaxJSON,
+	".map":  SyntaxJSON,
+	".html": SyntaxHTML,
+	".htm":  SyntaxHTML,
+}
+
+// Returns the syntax of a file, based on its extension
+func SyntaxFromPath(filePath string) Syntax {
+	if syntax, ok := syntaxByExtension[strings.ToLower(path.Ext(filePath))]; ok {
+		return syntax
+	}
+	return SyntaxRaw
+}
+
+// Reports whether the literals (strings and templates) of a syntax are tracked (see `jsLexer`)
+func hasLiterals(syntax Syntax) bool {
+	return syntax == SyntaxJS || syntax == SyntaxJSON
+}
+
+type lexState int
+
+const (
+	lexCode lexState = iota
+	lexDoubleQuote
+	lexSingleQuote
+	lexTemplate
+	lexRegex
+	lexLineComment
+	lexBlockComment
+)
+
+// Keywords that can be followed by a regex literal (rather than a division)
+var lexRegexKeywords = map[string]bool{
+	"return":     true,
+	"typeof":     true,
+	"instanceof": true,
+	"case":       true,
+	"do":         true,
+	"else":       true,
+	"in":         true,
+	"new":        true,
+	"delete":     true,
+	"void":       true,
+	"throw":      true,
+	"yield":      true,
+	"await":      true,
+}
+
+// Minimal JS lexer, which only tracks whether the current position
+// is within a string, template, regex or comment.
+//
+// Bytes are fed one at a time, so a file can be lexed in a single pass
+// (or in chunks) without backtracking.
+type jsLexer struct {
+	state lexState
+	// Previous byte was a `\`
+	escaped bool
+	// Previous byte was a `/` in code (may start a comment or regex)
+	slash bool
+	// Previous byte was a `$` in a template (may start `${`)
+	dollar bool
+	// Previous byte was a `*` in a block comment (may end it)
+	star bool
+	// Within a regex character class `[...]`
+	regexClass bool
+	// Current `{` nesting depth in code
+	braceDepth int
+	// Brace depth at each (nested) template `${` expression
+	templateStack []int
+	// Last non-whitespace byte in code
+	lastSignificant byte
+	// Last identifier in code (used to detect keywords before a regex)
+	lastWord []byte
+	// Last byte in code was part of an identifier
+	inWord bool
+	// Offset of the next byte to be fed
+	offset int
+	// Offset of the opening quote of the current (or last) literal
+	literalStart int
+	// Last two bytes fed (the most recent last)
+	recent [2]byte
+}
+
+func isIdentifierByte(b byte) bool {
+	return b == '_' || b == '$' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b >= 0x80
+}
+
+func isWhitespaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f' || b == '\v'
+}
+
+// Bytes in code that change the state (rather than only the last significant byte
+// and identifier), and are fed one at a time (see `jsLexer.skip`)
+var lexCodeStops = func() (stops [256]bool) {
+	for _, c := range []byte("\"'`/{}") {
+		stops[c] = true
+	}
+	return stops
+}()
+
+// Returns the literal enclosing the next byte to be fed
+func (l *jsLexer) literal() Literal {
+	switch l.state {
+	case lexDoubleQuote:
+		return LiteralDoubleQuote
+	case lexSingleQuote:
+		return LiteralSingleQuote
+	case lexTemplate:
+		return LiteralTemplate
+	}
+	return LiteralNone
+}
+
+// Feeds every byte in `b`
+func (l *jsLexer) write(b []byte) {
+	start := l.offset
+
+	for i := 0; i < len(b); {
+		l.offset = start + i
+		if n := l.skip(b[i:]); n > 0 {
+			i += n
+			continue
+		}
+		l.feed(b[i])
+		i++
+	}
+
+	l.offset = start + len(b)
+
+	switch len(b) {
+	case 0:
+	case 1:
+		l.recent[0], l.recent[1] = l.recent[1], b[0]
+	default:
+		l.recent[0], l.recent[1] = b[len(b)-2], b[len(b)-1]
+	}
+}
+
+// Returns the number of bytes at the start of `b` that would not change the
+// state (other than the current identifier), and feeds them all at once.
+//
+// Most of a minified bundle is strings and identifiers, so this avoids
+// feeding them one byte at a time.
+func (l *jsLexer) skip(b []byte) int {
+	if l.escaped {
+		return 0
+	}
+
+	n := 0
+
+	switch l.state {
+	case lexCode:
+		if l.slash {
+			return 0
+		}
+		for n < len(b) && !lexCodeStops[b[n]] {
+			n++
+		}
+		l.skipCode(b[:n])
+
+	case lexDoubleQuote, lexSingleQuote:
+		quote := byte('"')
+		if l.state == lexSingleQuote {
+			quote = '\''
+		}
+		for n < len(b) && b[n] != quote && b[n] != '\\' && b[n] != '\n' {
+			n++
+		}
+
+	case lexTemplate:
+		if l.dollar {
+			return 0
+		}
+		for n < len(b) && b[n] != '`' && b[n] != '\\' && b[n] != '$' {
+			n++
+		}
+
+	case lexLineComment:
+		if n = bytes.IndexByte(b, '\n'); n < 0 {
+			n = len(b)
+		}
+
+	case lexBlockComment:
+		if l.star {
+			return 0
+		}
+		if n = bytes.IndexByte(b, '*'); n < 0 {
+			n = len(b)
+		}
+	}
+
+	return n
+}
+
+// Feeds `b` in code, which must not contain quotes, `/`, `{` or `}` (so only the
+// last significant byte and identifier are changed)
+func (l *jsLexer) skipCode(b []byte) {
+	end := len(b)
+	for end > 0 && isWhitespaceByte(b[end-1]) {
+		end--
+	}
+
+	if end == 0 {
+		l.inWord = l.inWord && len(b) == 0
+		return
+	}
+
+	last := b[end-1]
+	l.lastSignificant = last
+
+	if isIdentifierByte(last) {
+		start := end - 1
+		for start > 0 && isIdentifierByte(b[start-1]) {
+			start--
+		}
+		// The identifier continues from the last bytes fed
+		if start > 0 || !l.inWord {
+			l.lastWord = l.lastWord[:0]
+		}
+		l.lastWord = append(l.lastWord, b[start:end]...)
+	}
+
+	l.inWord = end == len(b) && isIdentifierByte(last)
+}
+
+// Reports whether the current literal was opened by the last byte fed,
+// and will be closed by `next` (i.e. the bytes in between make up the entire literal)
+func (l *jsLexer) isWholeLiteral(next byte) bool {
+	quote, ok := literalQuotes[l.literal()]
+	return ok && l.literalStart == l.offset-1 && next == quote
+}
+
+// Reports whether a `/` at the current position starts a regex (rather than a division)
+func (l *jsLexer) regexAllowed() bool {
+	if l.lastSignificant == 0 {
+		return true
+	}
+	if isIdentifierByte(l.lastSignificant) {
+		return lexRegexKeywords[string(l.lastWord)]
+	}
+	return !strings.ContainsRune(")]}", rune(l.lastSignificant))
+}
+
+func (l *jsLexer) feed(c byte) {
+	switch l.state {
+	case lexCode:
+		if l.slash {
+			l.slash = false
+			switch {
+			case c == '/':
+				l.state = lexLineComment
+				return
+			case c == '*':
+				l.state = lexBlockComment
+				return
+			case l.regexAllowed():
+				l.state = lexRegex
+				l.feed(c)
+				return
+			default:
+				l.lastSignificant = '/'
+				l.inWord = false
+			}
+		}
+		l.feedCode(c)
+
+	case lexDoubleQuote, lexSingleQuote:
+		quote := byte('"')
+		if l.state == lexSingleQuote {
+			quote = '\''
+		}
+		switch {
+		case l.escaped:
+			l.escaped = false
+		case c == '\\':
+			l.escaped = true
+		case c == quote, c == '\n':
+			l.state = lexCode
+			l.lastSignificant = quote
+		}
+
+	case lexTemplate:
+		if l.dollar {
+			l.dollar = false
+			if c == '{' {
+				l.templateStack = append(l.templateStack, l.braceDepth)
+				l.braceDepth++
+				l.state = lexCode
+				l.lastSignificant = '{'
+				return
+			}
+		}
+		switch {
+		case l.escaped:
+			l.escaped = false
+		case c == '\\':
+			l.escaped = true
+		case c == '$':
+			l.dollar = true
+		case c == '`':
+			l.state = lexCode
+			l.lastSignificant = '`'
+		}
+
+	case lexRegex:
+		switch {
+		case l.escaped:
+			l.escaped = false
+		case c == '\\':
+			l.escaped = true
+		case c == '[':
+			l.regexClass = true
+		case c == ']':
+			l.regexClass = false
+		case c == '/' && !l.regexClass, c == '\n':
+			l.state = lexCode
+			l.regexClass = false
+			l.lastSignificant = ')'
+		}
+
+	case lexLineComment:
+		if c == '\n' {
+			l.state = lexCode
+		}
+
+	case lexBlockComment:
+		if l.star && c == '/' {
+			l.state = lexCode
+		}
+		l.star = c == '*'
+	}
+}
+
+func (l *jsLexer) feedCode(c byte) {
+	if isWhitespaceByte(c) {
+		l.inWord = false
+		return
+	}
+
+	if isIdentifierByte(c) {
+		if !l.inWord {
+			l.lastWord = l.lastWord[:0]
+		}
+		l.lastWord = append(l.lastWord, c)
+		l.inWord = true
+		l.lastSignificant = c
+		return
+	}
+
+	l.inWord = false
+
+	switch c {
+	case '"':
+		l.state = lexDoubleQuote
+		l.literalStart = l.offset
+	case '\'':
+		l.state = lexSingleQuote
+		l.literalStart = l.offset
+	case '`':
+		l.state = lexTemplate
+		l.literalStart = l.offset
+	case '/':
+		l.slash = true
+		return
+	case '{':
+		l.braceDepth++
+	case '}':
+		l.braceDepth--
+		if n := len(l.templateStack); n > 0 && l.templateStack[n-1] == l.braceDepth {
+			l.templateStack = l.templateStack[:n-1]
+			l.state = lexTemplate
+			return
+		}
+	}
+
+	l.lastSignificant = c
+}
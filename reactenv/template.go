@@ -0,0 +1,427 @@
+package reactenv
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// Default directory (within `Reactenv.Dir`) where templates are kept
+	REACTENV_TEMPLATE_DIR      = ".reactenv"
+	REACTENV_TEMPLATE_MANIFEST = "manifest.json"
+	REACTENV_TEMPLATE_FILES    = "templates"
+)
+
+// Tracks the template (original contents, with placeholders) of every injected file
+type TemplateManifest = struct {
+	Files map[string]*TemplateManifestFile `json:"files"`
+}
+type TemplateManifestFile = struct {
+	// sha256 of the template contents
+	Template string `json:"template"`
+	// sha256 of the contents after injection
+	Rendered string `json:"rendered"`
+	// Compression of the injected file (hashes are of the decompressed contents)
+	Compression Compression `json:"compression,omitempty"`
+}
+
+func hashContents(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the directory templates are kept in
+func (r *Reactenv) TemplateDirPath() string {
+	if r.TemplateDir != "" {
+		return r.TemplateDir
+	}
+	return filepath.Join(r.Dir, REACTENV_TEMPLATE_DIR)
+}
+
+func (r *Reactenv) templateManifestPath() string {
+	return filepath.Join(r.TemplateDirPath(), REACTENV_TEMPLATE_MANIFEST)
+}
+
+func (r *Reactenv) templateFilePath(relPath string) string {
+	return filepath.Join(r.TemplateDirPath(), REACTENV_TEMPLATE_FILES, filepath.FromSlash(relPath)+".gz")
+}
+
+// Reads the template manifest. Returns an empty manifest if none exists.
+func (r *Reactenv) LoadTemplateManifest() (*TemplateManifest, error) {
+	manifest := &TemplateManifest{
+		Files: make(map[string]*TemplateManifestFile),
+	}
+
+	contents, err := os.ReadFile(r.templateManifestPath())
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(contents, manifest); err != nil {
+		return nil, fmt.Errorf("template manifest '%s' is not valid: %w", r.templateManifestPath(), err)
+	}
+
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]*TemplateManifestFile)
+	}
+
+	return manifest, nil
+}
+
+// Opens the template of a file. The template is checked against `templateHash` once fully read.
+func (r *Reactenv) openTemplate(relPath string, templateHash string) (io.ReadCloser, error) {
+	templateFile, err := os.Open(r.templateFilePath(relPath))
+
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := gzip.NewReader(templateFile)
+
+	if err != nil {
+		templateFile.Close()
+		return nil, err
+	}
+
+	return &templateReader{
+		reader:   reader,
+		file:     templateFile,
+		hash:     sha256.New(),
+		expected: templateHash,
+		relPath:  relPath,
+	}, nil
+}
+
+// Reads the template of a file
+func (r *Reactenv) readTemplate(relPath string, templateHash string) ([]byte, error) {
+	reader, err := r.openTemplate(relPath, templateHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// Decompresses a template, and returns an error at EOF if it does not match its manifest hash
+type templateReader struct {
+	reader   *gzip.Reader
+	file     *os.File
+	hash     hash.Hash
+	expected string
+	relPath  string
+}
+
+func (t *templateReader) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+	t.hash.Write(p[:n])
+
+	if errors.Is(err, io.EOF) && hex.EncodeToString(t.hash.Sum(nil)) != t.expected {
+		return n, fmt.Errorf("template for '%s' does not match its manifest hash", t.relPath)
+	}
+
+	return n, err
+}
+
+func (t *templateReader) Close() error {
+	t.reader.Close()
+	return t.file.Close()
+}
+
+// Returns the sha256 of a file (decompressed, if needed)
+func hashFile(filePath string, compression Compression) (string, error) {
+	file, err := openDecompressed(filePath, compression)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Returns the manifest entry of a file (safe to call while files are processed in parallel)
+func (r *Reactenv) templateEntry(relPath string) (*TemplateManifestFile, bool) {
+	if r.templateManifest == nil {
+		return nil, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.templateManifest.Files[relPath]
+	return entry, ok
+}
+
+// Reads the contents of a file to be injected.
+//
+// If the file has already been injected (its contents match the rendered hash
+// in the manifest), the template is returned instead, so it can be injected again.
+func (r *Reactenv) ReadFile(file *File) ([]byte, error) {
+	fileContents, err := readDecompressed(r.FilePath(file), file.Compression)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := r.templateEntry(file.Path)
+
+	if !ok || hashContents(fileContents) != entry.Rendered {
+		return fileContents, nil
+	}
+
+	return r.readTemplate(file.Path, entry.Template)
+}
+
+// Opens a file to be injected, without reading it into memory.
+//
+// Streaming version of `ReadFile`, opens the template instead if the file has already been injected.
+func (r *Reactenv) OpenFile(file *File) (io.ReadCloser, error) {
+	filePath := r.FilePath(file)
+
+	if entry, ok := r.templateEntry(file.Path); ok {
+		fileHash, err := hashFile(filePath, file.Compression)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if fileHash == entry.Rendered {
+			return r.openTemplate(file.Path, entry.Template)
+		}
+	}
+
+	return openDecompressed(filePath, file.Compression)
+}
+
+// Updates the rendered hash of a file that has changed again since it was injected (see `stageProcessed`)
+func (r *Reactenv) updateRendered(relPath string, renderedHash string) {
+	if entry, ok := r.templateEntry(relPath); ok {
+		r.mu.Lock()
+		entry.Rendered = renderedHash
+		r.mu.Unlock()
+	}
+}
+
+// Stages the template of a file (written by `writeTemplate`), and updates the
+// manifest (which is staged by `stageTemplateManifest`)
+func (r *Reactenv) stageTemplate(tx *Transaction, file *File, templateHash string, renderedHash string, writeTemplate func(w io.Writer) error) error {
+	templatePath := r.templateFilePath(file.Path)
+
+	entry, ok := r.templateEntry(file.Path)
+
+	// Template is unchanged (e.g. re-injecting from a template)
+	if ok && entry.Template == templateHash {
+		if _, err := os.Stat(templatePath); err == nil {
+			r.mu.Lock()
+			entry.Rendered = renderedHash
+			entry.Compression = file.Compression
+			r.mu.Unlock()
+			return nil
+		}
+	}
+
+	if err := tx.MkdirAll(filepath.Dir(templatePath), 0755); err != nil {
+		return err
+	}
+
+	err := tx.Stage(templatePath, func(w io.Writer) error {
+		writer, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+		if err := writeTemplate(writer); err != nil {
+			return err
+		}
+		return writer.Close()
+	})
+
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.templateManifest.Files[file.Path] = &TemplateManifestFile{
+		Template:    templateHash,
+		Rendered:    renderedHash,
+		Compression: file.Compression,
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Reactenv) stageTemplateManifest(tx *Transaction) error {
+	contents, err := json.MarshalIndent(r.templateManifest, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := tx.MkdirAll(r.TemplateDirPath(), 0755); err != nil {
+		return err
+	}
+
+	return tx.StageBytes(r.templateManifestPath(), contents)
+}
+
+// Restores the template of every injected file in `dir`, putting all placeholders back.
+//
+// Files that have been modified since they were injected are not restored
+// (and an error is returned), unless `force` is set. Once restored, the
+// templates are removed. Returns the path of every restored file.
+func (r *Reactenv) RestoreTemplates(ctx context.Context, dir string, force bool) ([]string, error) {
+	r.Dir = dir
+
+	manifest, err := r.LoadTemplateManifest()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("no templates found in '%s'", r.TemplateDirPath())
+	}
+
+	relPaths := make([]string, 0, len(manifest.Files))
+	for relPath := range manifest.Files {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	modified := make([]string, 0)
+	restored := make([]string, 0)
+	tx := NewTransaction(ctx)
+
+	for _, relPath := range relPaths {
+		entry := manifest.Files[relPath]
+		filePath := filepath.Join(r.Dir, filepath.FromSlash(relPath))
+
+		fileHash, err := hashFile(filePath, entry.Compression)
+
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if err == nil && fileHash == entry.Template {
+			continue
+		}
+
+		if (err != nil || fileHash != entry.Rendered) && !force {
+			modified = append(modified, relPath)
+			continue
+		}
+
+		template, err := r.readTemplate(relPath, entry.Template)
+
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if err := r.stageRestored(tx, relPath, entry.Compression, template); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		restored = append(restored, relPath)
+	}
+
+	if len(modified) > 0 {
+		tx.Rollback()
+		return nil, fmt.Errorf("files have been modified (or removed) since they were injected: '%s'", strings.Join(modified, "', '"))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Templates are no longer needed, as the files are templates again
+	os.RemoveAll(filepath.Join(r.TemplateDirPath(), REACTENV_TEMPLATE_FILES))
+	os.Remove(r.templateManifestPath())
+	os.Remove(r.TemplateDirPath())
+
+	return restored, nil
+}
+
+// Stages the restored template of a file, recompressing it to match the
+// current file (or at the highest level, if it has been removed). Compressed
+// siblings of uncompressed files are regenerated.
+func (r *Reactenv) stageRestored(tx *Transaction, relPath string, compression Compression, template []byte) error {
+	filePath := filepath.Join(r.Dir, filepath.FromSlash(relPath))
+
+	if compression == CompressionNone {
+		if err := tx.StageBytes(filePath, template); err != nil {
+			return err
+		}
+		return r.stageCompressedSiblings(tx, relPath)
+	}
+
+	options, err := readCompressionOptions(filePath, compression)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		options = defaultCompressionOptions(compression)
+	} else if err != nil {
+		return err
+	}
+
+	return tx.Stage(filePath, compressWrite(options, func(w io.Writer) error {
+		_, err := w.Write(template)
+		return err
+	}))
+}
+
+// Loads templates, if enabled. Called by `FindFiles`.
+func (r *Reactenv) loadTemplates() error {
+	r.templateManifest = nil
+
+	if !r.Templates {
+		return nil
+	}
+
+	manifest, err := r.LoadTemplateManifest()
+
+	if err != nil {
+		return err
+	}
+
+	r.templateManifest = manifest
+	return nil
+}
+
+// Reports whether `dirPath` is the template directory (which is never scanned)
+func (r *Reactenv) isTemplateDir(dirPath string) bool {
+	templateDir, err := filepath.Abs(r.TemplateDirPath())
+	if err != nil {
+		return false
+	}
+	dirPath, err = filepath.Abs(dirPath)
+	return err == nil && dirPath == templateDir
+}
@@ -0,0 +1,438 @@
+package reactenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Returned when a transaction is rolled back because its context was cancelled (e.g. after SIGINT/SIGTERM)
+var ErrInterrupted = errors.New("interrupted, all changes have been rolled back")
+
+var errTransactionDone = errors.New("transaction has already been committed or rolled back")
+
+// Writes to multiple files as a single transaction.
+//
+// New contents are staged (in temporary files) for every file first, then
+// committed together. If any commit step fails, or the context is cancelled
+// before the commit completes, every file is restored to its original contents.
+type Transaction struct {
+	ctx    context.Context
+	staged []*stagedFile
+	byPath map[string]*stagedFile
+	// Directories created by `MkdirAll`, removed if rolled back
+	dirs []string
+	// Transaction has been committed or rolled back
+	done bool
+	// Guards `staged` and `byPath`, files can be staged in parallel
+	mu sync.Mutex
+}
+
+type stagedFile struct {
+	// File being written to
+	path string
+	// Temporary file with the new contents
+	tempPath string
+	// Hard link (or copy) of the original contents, made when committing
+	backupPath string
+	// File did not exist before the transaction
+	created bool
+	// Temporary file has been renamed over `path`
+	committed bool
+}
+
+// Starts a new transaction, which is rolled back if `ctx` is cancelled before it is committed
+func NewTransaction(ctx context.Context) *Transaction {
+	return &Transaction{
+		ctx:    ctx,
+		staged: make([]*stagedFile, 0),
+		byPath: make(map[string]*stagedFile),
+	}
+}
+
+// Returns `ErrInterrupted` (after rolling back) if the context has been cancelled
+func (t *Transaction) checkInterrupted() error {
+	if err := t.ctx.Err(); err != nil {
+		t.Rollback()
+		return fmt.Errorf("%w (%w)", ErrInterrupted, err)
+	}
+	return nil
+}
+
+// Stages new contents for `filePath` (written by `write`), replacing any earlier staged contents.
+//
+// Files can be staged in parallel. If the context is cancelled, `ErrInterrupted`
+// is returned (and the transaction should be rolled back).
+func (t *Transaction) Stage(filePath string, write func(w io.Writer) error) error {
+	if err := t.checkStage(); err != nil {
+		return err
+	}
+
+	targetPath, err := resolveSymlink(filePath)
+
+	if err != nil {
+		return err
+	}
+
+	tempPath, err := CreateTempFile(targetPath, write)
+
+	if err != nil {
+		return err
+	}
+
+	return t.add(targetPath, tempPath)
+}
+
+// Stages new contents for `filePath` (written by `write`), taking the mode,
+// ownership and modification time of `like`. Unlike `Stage`, a symlink at
+// `filePath` is replaced, rather than followed.
+func (t *Transaction) StageLike(filePath string, like fs.FileInfo, write func(w io.Writer) error) error {
+	if err := t.checkStage(); err != nil {
+		return err
+	}
+
+	tempPath, err := createTempFileLike(filePath, like, write)
+
+	if err != nil {
+		return err
+	}
+
+	return t.add(filePath, tempPath)
+}
+
+// Stages a hard link (or copy) of `sourcePath` at `filePath`.
+// Nothing is staged if `filePath` is already a hard link to `sourcePath`.
+func (t *Transaction) StageLink(filePath string, sourcePath string) error {
+	if err := t.checkStage(); err != nil {
+		return err
+	}
+
+	if isSameFile(filePath, sourcePath) {
+		return nil
+	}
+
+	tempPath, err := createTempLink(filePath, sourcePath)
+
+	if err != nil {
+		return err
+	}
+
+	return t.add(filePath, tempPath)
+}
+
+// Stages a symlink to `target` at `filePath`
+func (t *Transaction) StageSymlink(filePath string, target string) error {
+	if err := t.checkStage(); err != nil {
+		return err
+	}
+
+	tempPath, err := createTempSymlink(filePath, target)
+
+	if err != nil {
+		return err
+	}
+
+	return t.add(filePath, tempPath)
+}
+
+// Adds a staged temporary file, to be renamed over `targetPath` when committed
+func (t *Transaction) add(targetPath string, tempPath string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Rolled back while writing
+	if t.done {
+		os.Remove(tempPath)
+		return errTransactionDone
+	}
+
+	if staged, ok := t.byPath[targetPath]; ok {
+		os.Remove(staged.tempPath)
+		staged.tempPath = tempPath
+		return nil
+	}
+
+	staged := &stagedFile{
+		path:     targetPath,
+		tempPath: tempPath,
+	}
+
+	t.staged = append(t.staged, staged)
+	t.byPath[targetPath] = staged
+
+	return nil
+}
+
+func (t *Transaction) checkStage() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		return errTransactionDone
+	}
+
+	if err := t.ctx.Err(); err != nil {
+		return fmt.Errorf("%w (%w)", ErrInterrupted, err)
+	}
+
+	return nil
+}
+
+// Creates `dirPath` (and any missing parents) straight away, as files can only
+// be staged within an existing directory. Every directory created is removed
+// (if still empty) when the transaction is rolled back.
+func (t *Transaction) MkdirAll(dirPath string, perm fs.FileMode) error {
+	if err := t.checkStage(); err != nil {
+		return err
+	}
+
+	// Directories that do not exist yet, deepest first
+	missing := make([]string, 0)
+	for dir := filepath.Clean(dirPath); ; dir = filepath.Dir(dir) {
+		_, err := os.Stat(dir)
+
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		missing = append(missing, dir)
+
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		err := os.Mkdir(missing[i], perm)
+
+		// Created by a file staged in parallel
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		t.mu.Lock()
+		t.dirs = append(t.dirs, missing[i])
+		t.mu.Unlock()
+	}
+
+	return nil
+}
+
+// Stages `data` as the new contents of `filePath`
+func (t *Transaction) StageBytes(filePath string, data []byte) error {
+	return t.Stage(filePath, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// Returns the path of every staged file
+func (t *Transaction) StagedPaths() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	paths := make([]string, 0, len(t.staged))
+	for _, staged := range t.staged {
+		paths = append(paths, staged.path)
+	}
+	return paths
+}
+
+// Reads the staged contents of `filePath`, or the current contents if it has not been staged
+func (t *Transaction) ReadFile(filePath string) ([]byte, error) {
+	file, err := t.Open(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// Opens the staged contents of `filePath`, or the current contents if it has not been staged
+func (t *Transaction) Open(filePath string) (*os.File, error) {
+	targetPath, err := resolveSymlink(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	staged, ok := t.byPath[targetPath]
+	if !ok {
+		// Staged without following symlinks (see `StageLike`)
+		staged, ok = t.byPath[filePath]
+	}
+	t.mu.Unlock()
+
+	if ok {
+		return os.Open(staged.tempPath)
+	}
+
+	return os.Open(targetPath)
+}
+
+// Commits every staged file.
+//
+// Each original file is backed up (hard-linked where possible), before every
+// staged file is renamed over its original. If any step fails, all changes are
+// rolled back and an error is returned.
+func (t *Transaction) Commit() error {
+	if t.done {
+		return errTransactionDone
+	}
+
+	for _, staged := range t.staged {
+		if err := t.checkInterrupted(); err != nil {
+			return err
+		}
+
+		if err := staged.backup(); err != nil {
+			return t.rollbackWithError(fmt.Errorf("unable to backup '%s': %w", staged.path, err))
+		}
+	}
+
+	for _, staged := range t.staged {
+		if err := t.checkInterrupted(); err != nil {
+			return err
+		}
+
+		if err := os.Rename(staged.tempPath, staged.path); err != nil {
+			return t.rollbackWithError(fmt.Errorf("unable to write '%s': %w", staged.path, err))
+		}
+
+		staged.committed = true
+	}
+
+	for _, staged := range t.staged {
+		syncDir(filepath.Dir(staged.path))
+	}
+
+	// Last chance to roll back, before backups are removed
+	if err := t.checkInterrupted(); err != nil {
+		return err
+	}
+
+	t.finish()
+
+	for _, staged := range t.staged {
+		if staged.backupPath != "" {
+			os.Remove(staged.backupPath)
+		}
+	}
+
+	return nil
+}
+
+// Discards every staged file, and restores any files that have already been committed
+func (t *Transaction) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		return nil
+	}
+
+	t.finish()
+
+	errs := make([]error, 0)
+
+	for _, staged := range t.staged {
+		if err := staged.restore(); err != nil {
+			errs = append(errs, fmt.Errorf("unable to restore '%s': %w", staged.path, err))
+		}
+	}
+
+	// Deepest directories first, so parents are empty by the time they are removed
+	sort.Slice(t.dirs, func(i, j int) bool {
+		return len(t.dirs[i]) > len(t.dirs[j])
+	})
+	for _, dir := range t.dirs {
+		os.Remove(dir)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *Transaction) rollbackWithError(err error) error {
+	if rollbackErr := t.Rollback(); rollbackErr != nil {
+		return errors.Join(err, rollbackErr)
+	}
+	return fmt.Errorf("%w (all changes have been rolled back)", err)
+}
+
+func (t *Transaction) finish() {
+	t.done = true
+}
+
+// Keeps the original contents of a staged file, so it can be restored
+func (s *stagedFile) backup() error {
+	if _, err := os.Lstat(s.path); errors.Is(err, os.ErrNotExist) {
+		s.created = true
+		return nil
+	}
+
+	// Reserve a unique name, as `os.Link` requires the new path not to exist
+	backupPath, err := reserveTempPath(s.path, ".reactenv-backup-*")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Link(s.path, backupPath); err == nil {
+		s.backupPath = backupPath
+		return nil
+	}
+
+	// Hard links are not supported on every filesystem, fallback to a copy
+	backupPath, err = CreateTempFile(s.path, func(w io.Writer) error {
+		original, err := os.Open(s.path)
+		if err != nil {
+			return err
+		}
+		defer original.Close()
+		_, err = io.Copy(w, original)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	s.backupPath = backupPath
+	return nil
+}
+
+// Restores the original contents of a staged file, and removes any temporary files
+func (s *stagedFile) restore() error {
+	os.Remove(s.tempPath)
+
+	if !s.committed {
+		if s.backupPath != "" {
+			os.Remove(s.backupPath)
+		}
+		return nil
+	}
+
+	if s.created {
+		return os.Remove(s.path)
+	}
+
+	return os.Rename(s.backupPath, s.path)
+}
@@ -0,0 +1,210 @@
+package reactenv
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// Returns the contents of every file in `dir`, by name
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		contents, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(contents)
+	}
+	return files
+}
+
+// Asserts `dir` contains exactly `want` (so no temporary or backup files are left behind)
+func assertDir(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+
+	got := readDir(t, dir)
+
+	names := make([]string, 0, len(got))
+	for name := range got {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(got) != len(want) {
+		t.Errorf("directory contains %v, want %d files", names, len(want))
+	}
+
+	for name, contents := range want {
+		if got[name] != contents {
+			t.Errorf("'%s' contains %q, want %q", name, got[name], contents)
+		}
+	}
+}
+
+// Writes `a`, `b`, `c` and `d` (with their original contents), and stages new contents for each, and a new file `e`
+func stageFiles(t *testing.T, tx *Transaction, dir string) {
+	t.Helper()
+
+	writeFiles(t, dir, map[string]string{"a": "a1", "b": "b1", "c": "c1", "d": "d1"})
+
+	for _, name := range []string{"a", "b", "e", "c", "d"} {
+		if err := tx.StageBytes(filepath.Join(dir, name), []byte(name+"2")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var originalFiles = map[string]string{"a": "a1", "b": "b1", "c": "c1", "d": "d1"}
+
+func TestTransactionCommit(t *testing.T) {
+	dir := t.TempDir()
+	tx := NewTransaction(context.Background())
+	stageFiles(t, tx, dir)
+
+	// Staged contents are read before they are committed
+	if contents, err := tx.ReadFile(filepath.Join(dir, "a")); err != nil || string(contents) != "a2" {
+		t.Errorf("ReadFile of a staged file = %q, %v, want \"a2\"", contents, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertDir(t, dir, map[string]string{"a": "a2", "b": "b2", "c": "c2", "d": "d2", "e": "e2"})
+
+	if err := tx.Commit(); !errors.Is(err, errTransactionDone) {
+		t.Errorf("second Commit = %v, want errTransactionDone", err)
+	}
+
+	if err := tx.StageBytes(filepath.Join(dir, "a"), []byte("a3")); !errors.Is(err, errTransactionDone) {
+		t.Errorf("Stage after Commit = %v, want errTransactionDone", err)
+	}
+}
+
+func TestTransactionCommitFailedRename(t *testing.T) {
+	// Fail to rename the file at each position, every file before it must be restored
+	for failIndex := range 5 {
+		dir := t.TempDir()
+		tx := NewTransaction(context.Background())
+		stageFiles(t, tx, dir)
+
+		failed := tx.staged[failIndex]
+		if err := os.Remove(failed.tempPath); err != nil {
+			t.Fatal(err)
+		}
+
+		err := tx.Commit()
+
+		if err == nil {
+			t.Fatalf("Commit with a missing temporary file for '%s' succeeded", filepath.Base(failed.path))
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Commit = %v, want the rename error", err)
+		}
+
+		assertDir(t, dir, originalFiles)
+
+		if err := tx.Rollback(); err != nil {
+			t.Errorf("Rollback after a failed Commit = %v, want nil", err)
+		}
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	dir := t.TempDir()
+	tx := NewTransaction(context.Background())
+	stageFiles(t, tx, dir)
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertDir(t, dir, originalFiles)
+
+	if err := tx.Commit(); !errors.Is(err, errTransactionDone) {
+		t.Errorf("Commit after Rollback = %v, want errTransactionDone", err)
+	}
+}
+
+func TestTransactionInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	tx := NewTransaction(ctx)
+	stageFiles(t, tx, dir)
+
+	cancel()
+
+	if err := tx.Commit(); !errors.Is(err, ErrInterrupted) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Commit after cancel = %v, want ErrInterrupted", err)
+	}
+
+	assertDir(t, dir, originalFiles)
+
+	if err := tx.StageBytes(filepath.Join(dir, "a"), []byte("a3")); err == nil {
+		t.Errorf("Stage after cancel succeeded")
+	}
+}
+
+func TestTransactionStageReplaces(t *testing.T) {
+	dir := t.TempDir()
+	tx := NewTransaction(context.Background())
+	filePath := filepath.Join(dir, "a")
+
+	for _, contents := range []string{"a1", "a2", "a3"} {
+		if err := tx.StageBytes(filePath, []byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if paths := tx.StagedPaths(); len(paths) != 1 {
+		t.Errorf("StagedPaths = %v, want a single path", paths)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertDir(t, dir, map[string]string{"a": "a3"})
+}
+
+func TestTransactionMkdirAllRollback(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a": "a1", "existing/b": "b1"})
+
+	tx := NewTransaction(context.Background())
+
+	for _, dirPath := range []string{filepath.Join(dir, "new", "nested"), filepath.Join(dir, "existing", "new"), filepath.Join(dir, "existing")} {
+		if err := tx.MkdirAll(dirPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tx.StageBytes(filepath.Join(dir, "new", "nested", "c"), []byte("c2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, removed := range []string{"new", filepath.Join("existing", "new")} {
+		if _, err := os.Stat(filepath.Join(dir, removed)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("'%s' was not removed when rolled back (%v)", removed, err)
+		}
+	}
+
+	assertDir(t, filepath.Join(dir, "existing"), map[string]string{"b": "b1"})
+}
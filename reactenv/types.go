@@ -0,0 +1,66 @@
+package reactenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Type annotation of a placeholder, e.g. `__reactenv.FEATURE_ENABLED:bool`
+type ValueType = string
+
+const (
+	// Placeholder has no type annotation, and is injected as a string
+	TypeNone   ValueType = ""
+	TypeString ValueType = "string"
+	TypeBool   ValueType = "bool"
+	TypeNumber ValueType = "number"
+	TypeJSON   ValueType = "json"
+)
+
+// Valid JSON (and JS) number literal
+var numberExpression = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// Reports whether `valueType` is injected as a JS literal (rather than a string)
+func IsLiteralType(valueType ValueType) bool {
+	return valueType == TypeBool || valueType == TypeNumber || valueType == TypeJSON
+}
+
+// Converts `value` into a JS literal of `valueType`.
+//
+// Returns an error if `value` is not valid for `valueType`.
+func FormatTypedValue(value string, valueType ValueType) (string, error) {
+	trimmed := strings.TrimSpace(value)
+
+	switch valueType {
+	case TypeBool:
+		parsed, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return "", errors.New("value is not a valid bool (expected true or false)")
+		}
+		return strconv.FormatBool(parsed), nil
+
+	case TypeNumber:
+		if !numberExpression.MatchString(trimmed) {
+			return "", errors.New("value is not a valid number")
+		}
+		return trimmed, nil
+
+	case TypeJSON:
+		var out bytes.Buffer
+		if err := json.Compact(&out, []byte(trimmed)); err != nil {
+			return "", fmt.Errorf("value is not valid JSON: %w", err)
+		}
+
+		// Escape `<`, `>`, `&` and line terminators, in case the JS is inlined in HTML
+		var escaped bytes.Buffer
+		json.HTMLEscape(&escaped, out.Bytes())
+		return escaped.String(), nil
+	}
+
+	return value, nil
+}
@@ -0,0 +1,113 @@
+package reactenv
+
+import (
+	"io"
+	"testing"
+)
+
+func TestFormatTypedValue(t *testing.T) {
+	tests := []struct {
+		value     string
+		valueType ValueType
+		want      string
+		wantErr   bool
+	}{
+		{"true", TypeBool, "true", false},
+		{" 1 ", TypeBool, "true", false},
+		{"False", TypeBool, "false", false},
+		{"yes", TypeBool, "", true},
+		{"42", TypeNumber, "42", false},
+		{"-1.5e3", TypeNumber, "-1.5e3", false},
+		{"0x10", TypeNumber, "", true},
+		{"NaN", TypeNumber, "", true},
+		{"01", TypeNumber, "", true},
+		{`{ "a": [1, 2] }`, TypeJSON, `{"a":[1,2]}`, false},
+		{`{"a":"</script>"}`, TypeJSON, `{"a":"\u003c/script\u003e"}`, false},
+		{`{"a":}`, TypeJSON, "", true},
+		{`a"b`, TypeString, `a"b`, false},
+	}
+
+	for _, test := range tests {
+		got, err := FormatTypedValue(test.value, test.valueType)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("FormatTypedValue(%q, %q) = %q, %v, want %q (error %v)", test.value, test.valueType, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestRenderTypedValues(t *testing.T) {
+	values := map[string]string{"F": "true", "N": "-5", "P": "5", "J": `{"a":1}`, "A": "[1]", "S": "a"}
+
+	tests := []struct {
+		contents string
+		want     string
+	}{
+		{`const f="__reactenv.F:bool";`, `const f=true;`},
+		{`const s="__reactenv.S:string";`, `const s="a";`},
+		{`const s="x__reactenv.S";`, `const s="xa";`},
+
+		// Literals that would join the code either side
+		{`return"__reactenv.F:bool"`, `return true`},
+		{`return"__reactenv.F:bool"in a`, `return true in a`},
+		{`y-"__reactenv.N:number"`, `y- (-5)`},
+		{`y+"__reactenv.P:number"`, `y+ 5`},
+		{`"__reactenv.P:number".toFixed()`, `5 .toFixed()`},
+		{`y-__reactenv.N:number`, `y- (-5)`},
+		{`()=>"__reactenv.J:json"`, `()=>({"a":1})`},
+		{`()=>"__reactenv.A:json"`, `()=>([1])`},
+		{`return"__reactenv.X:bool?"`, `return undefined`},
+		{`a=["__reactenv.N:number"]`, `a=[(-5)]`},
+	}
+
+	for _, test := range tests {
+		if got := render(t, test.contents, SyntaxJS, values); got != test.want {
+			t.Errorf("render(%q) = %q, want %q", test.contents, got, test.want)
+		}
+	}
+
+	jsonTests := []struct {
+		contents string
+		want     string
+	}{
+		{`{"n":"__reactenv.N:number","j":"__reactenv.J:json"}`, `{"n":-5,"j":{"a":1}}`},
+		{`{"x":"__reactenv.X:bool?"}`, `{"x":null}`},
+	}
+
+	for _, test := range jsonTests {
+		if got := render(t, test.contents, SyntaxJSON, values); got != test.want {
+			t.Errorf("render(%q) = %q, want %q", test.contents, got, test.want)
+		}
+	}
+}
+
+// Typed values at the end of a chunk must wait for the byte after them
+func TestScanTypedValueReader(t *testing.T) {
+	contents := `return"__reactenv.F:bool"in a`
+
+	for size := 1; size <= len(contents); size++ {
+		occurrences, err := ScanOccurrencesReader(&chunkReader{contents: []byte(contents), size: size}, SyntaxJS)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(occurrences) != 1 || !occurrences[0].Unquote || !occurrences[0].PadStart || !occurrences[0].PadEnd {
+			t.Errorf("chunks of %d found %+v", size, occurrences)
+		}
+	}
+}
+
+// Reads `contents` in chunks of `size` bytes
+type chunkReader struct {
+	contents []byte
+	size     int
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(c.contents) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), c.size)], c.contents)
+	c.contents = c.contents[n:]
+	return n, nil
+}
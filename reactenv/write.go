@@ -0,0 +1,199 @@
+package reactenv
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Writes `data` to `filePath` atomically (see `WriteFileAtomicFunc`)
+func WriteFileAtomic(filePath string, data []byte) error {
+	return WriteFileAtomicFunc(filePath, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// Writes to `filePath` atomically, so a crash mid-write never leaves a truncated file.
+//
+// Contents are written (by `write`) to a temporary file in the same directory,
+// which is fsynced and then renamed over `filePath`. The original mode,
+// ownership (where possible) and modification time are kept.
+func WriteFileAtomicFunc(filePath string, write func(w io.Writer) error) error {
+	targetPath, err := resolveSymlink(filePath)
+
+	if err != nil {
+		return err
+	}
+
+	tempPath, err := CreateTempFile(targetPath, write)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, targetPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	syncDir(filepath.Dir(targetPath))
+
+	return nil
+}
+
+// Creates a temporary file in the same directory as `filePath`, with contents written by `write`.
+//
+// The file is fsynced, and takes the mode, ownership (where possible) and
+// modification time of `filePath` (if it exists), ready to be renamed over it.
+// Returns the path of the temporary file.
+func CreateTempFile(filePath string, write func(w io.Writer) error) (string, error) {
+	info, err := os.Stat(filePath)
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	return createTempFileLike(filePath, info, write)
+}
+
+// Creates a temporary file (see `CreateTempFile`), taking the mode, ownership
+// and modification time of `info` (or `0644` if `info` is nil)
+func createTempFileLike(filePath string, info fs.FileInfo, write func(w io.Writer) error) (string, error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".reactenv-*")
+
+	if err != nil {
+		return "", err
+	}
+
+	tempPath := tempFile.Name()
+
+	fail := func(err error) (string, error) {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return "", err
+	}
+
+	if err := write(tempFile); err != nil {
+		return fail(err)
+	}
+
+	if err := tempFile.Sync(); err != nil {
+		return fail(err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return fail(err)
+	}
+
+	if info == nil {
+		if err := os.Chmod(tempPath, 0644); err != nil {
+			return fail(err)
+		}
+		return tempPath, nil
+	}
+
+	// Ownership must be set before the mode, as changing owner can clear setuid/setgid bits
+	chownLike(tempPath, info)
+
+	if err := os.Chmod(tempPath, info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return fail(err)
+	}
+
+	if err := os.Chtimes(tempPath, time.Now(), info.ModTime()); err != nil {
+		return fail(err)
+	}
+
+	return tempPath, nil
+}
+
+// Reserves a unique path (that does not exist) in the same directory as `filePath`
+func reserveTempPath(filePath string, suffix string) (string, error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+suffix)
+
+	if err != nil {
+		return "", err
+	}
+
+	tempPath := tempFile.Name()
+	tempFile.Close()
+
+	return tempPath, os.Remove(tempPath)
+}
+
+// Creates a temporary hard link to `sourcePath`, in the same directory as `filePath`.
+//
+// Hard links are not supported on every filesystem (or across devices), so
+// `sourcePath` is copied instead if linking fails. Returns the path of the link.
+func createTempLink(filePath string, sourcePath string) (string, error) {
+	tempPath, err := reserveTempPath(filePath, ".reactenv-*")
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Link(sourcePath, tempPath); err == nil {
+		return tempPath, nil
+	}
+
+	info, err := os.Stat(sourcePath)
+
+	if err != nil {
+		return "", err
+	}
+
+	return createTempFileLike(filePath, info, func(w io.Writer) error {
+		source, err := os.Open(sourcePath)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+		_, err = io.Copy(w, source)
+		return err
+	})
+}
+
+// Reports whether `filePath` (not following symlinks) and `sourcePath` are the same file
+func isSameFile(filePath string, sourcePath string) bool {
+	fileInfo, err := os.Lstat(filePath)
+	if err != nil {
+		return false
+	}
+	sourceInfo, err := os.Stat(sourcePath)
+	return err == nil && os.SameFile(fileInfo, sourceInfo)
+}
+
+// Creates a temporary symlink to `target`, in the same directory as `filePath`
+func createTempSymlink(filePath string, target string) (string, error) {
+	tempPath, err := reserveTempPath(filePath, ".reactenv-*")
+
+	if err != nil {
+		return "", err
+	}
+
+	return tempPath, os.Symlink(target, tempPath)
+}
+
+// Returns the final path of `filePath`, following any symlinks
+// (so writes replace the target, rather than the symlink itself)
+func resolveSymlink(filePath string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(filePath)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return filePath, nil
+	}
+
+	return resolved, err
+}
+
+// Flushes a directory entry to disk, so a rename survives a crash. Errors are ignored,
+// as not every platform (or filesystem) supports syncing a directory.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
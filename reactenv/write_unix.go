@@ -0,0 +1,17 @@
+//go:build !windows
+
+package reactenv
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// Sets the owner of `filePath` to match `info`. Errors are ignored, as
+// only privileged users can change ownership.
+func chownLike(filePath string, info fs.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		os.Chown(filePath, int(stat.Uid), int(stat.Gid))
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package reactenv
+
+import (
+	"io/fs"
+)
+
+// Ownership is not supported on Windows
+func chownLike(filePath string, info fs.FileInfo) {}
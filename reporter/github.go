@@ -0,0 +1,38 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Escapes the message of a GitHub Actions workflow command
+var githubMessageEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// Escapes a property (e.g. `file`) of a GitHub Actions workflow command
+var githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// Writes a GitHub Actions annotation (`::error file=...::message`) for each
+// finding, which GitHub shows on the file (and in the summary of the run).
+//
+// See https://docs.github.com/actions/reference/workflow-commands-for-github-actions
+func WriteGitHub(w io.Writer, result *Result) error {
+	for _, finding := range result.Findings {
+		_, err := fmt.Fprintf(
+			w,
+			"::%s file=%s,line=%d,col=%d,title=%s::%s\n",
+			finding.Level,
+			githubPropertyEscaper.Replace(finding.Path),
+			finding.Line,
+			finding.Column,
+			githubPropertyEscaper.Replace("reactenv "+finding.Rule),
+			githubMessageEscaper.Replace(finding.Message),
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
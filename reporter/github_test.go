@@ -0,0 +1,13 @@
+package reporter
+
+import "testing"
+
+func TestWriteGitHub(t *testing.T) {
+	testReporter(t, WriteGitHub, testResult(), `::error file=dist/index.js,line=1,col=12,title=reactenv missing-value::Environment variable 'API_URL' is not set
+::error file=dist/a%2Cb%3Ac.js,line=3,col=5,title=reactenv invalid-value::Environment variable 'PORT' can not be injected: 100%25 <invalid>%0Anumber
+::warning file=dist/a%2Cb%3Ac.js,line=4,col=1,title=reactenv secret-key::Environment variable 'STRIPE_SECRET_KEY' looks like a secret
+::error file=dist/chunks/x.js,line=10,col=2,title=reactenv missing-value::Environment variable 'API_URL' is not set
+`)
+
+	testReporter(t, WriteGitHub, &Result{Command: "check", Keys: []Key{}, Findings: []Finding{}}, "")
+}
@@ -0,0 +1,91 @@
+package reporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// Writes a JUnit XML report, with a test case for each required environment
+// variable (and any other with an invalid value), failing when it is missing
+// or invalid. Each failure lists the location of every occurrence.
+func WriteJUnit(w io.Writer, result *Result) error {
+	// Error findings of each key, in file order
+	failures := make(map[string][]Finding)
+	for _, finding := range result.Findings {
+		if finding.Level == LevelError {
+			failures[finding.Key] = append(failures[finding.Key], finding)
+		}
+	}
+
+	seconds := fmt.Sprintf("%.3f", result.Duration.Seconds())
+	suite := junitTestSuite{Name: "reactenv " + result.Command, Time: seconds, TestCases: make([]junitTestCase, 0)}
+
+	for _, key := range result.Keys {
+		findings := failures[key.Name]
+
+		if !key.Required && len(findings) == 0 {
+			continue
+		}
+
+		testCase := junitTestCase{Name: key.Name, ClassName: "reactenv." + result.Command}
+
+		if len(findings) > 0 {
+			locations := make([]string, 0, len(findings))
+			for _, finding := range findings {
+				locations = append(locations, fmt.Sprintf("%s:%d:%d: %s", finding.Path, finding.Line, finding.Column, finding.Message))
+			}
+			testCase.Failure = &junitFailure{Message: findings[0].Message, Type: findings[0].Rule, Contents: strings.Join(locations, "\n")}
+			suite.Failures++
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.Tests++
+	}
+
+	report := junitTestSuites{Name: "reactenv", Tests: suite.Tests, Failures: suite.Failures, Time: seconds, Suites: []junitTestSuite{suite}}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,26 @@
+package reporter
+
+import "testing"
+
+// Each required key is a test case (and optional keys only when invalid), failing with every occurrence
+func TestWriteJUnit(t *testing.T) {
+	testReporter(t, WriteJUnit, testResult(), `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="reactenv" tests="3" failures="2" time="1.500">
+  <testsuite name="reactenv check" tests="3" failures="2" time="1.500">
+    <testcase name="API_URL" classname="reactenv.check">
+      <failure message="Environment variable &#39;API_URL&#39; is not set" type="missing-value">dist/index.js:1:12: Environment variable &#39;API_URL&#39; is not set&#xA;dist/chunks/x.js:10:2: Environment variable &#39;API_URL&#39; is not set</failure>
+    </testcase>
+    <testcase name="PORT" classname="reactenv.check">
+      <failure message="Environment variable &#39;PORT&#39; can not be injected: 100% &lt;invalid&gt;&#xA;number" type="invalid-value">dist/a,b:c.js:3:5: Environment variable &#39;PORT&#39; can not be injected: 100% &lt;invalid&gt;&#xA;number</failure>
+    </testcase>
+    <testcase name="TITLE" classname="reactenv.check"></testcase>
+  </testsuite>
+</testsuites>
+`)
+
+	testReporter(t, WriteJUnit, &Result{Command: "run", Keys: []Key{}, Findings: []Finding{}}, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="reactenv" tests="0" failures="0" time="0.000">
+  <testsuite name="reactenv run" tests="0" failures="0" time="0.000"></testsuite>
+</testsuites>
+`)
+}
@@ -0,0 +1,187 @@
+package reporter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/hmerritt/reactenv/reactenv"
+)
+
+// Rules of a finding
+const (
+	// Required environment variable is not set
+	RuleMissingValue = "missing-value"
+	// Value can not be injected (e.g. not valid for its type)
+	RuleInvalidValue = "invalid-value"
+	// Environment variable looks like a secret, and would be injected into a public bundle
+	RuleSecretKey = "secret-key"
+)
+
+// Levels of a finding
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// Matches keys that look like secrets (e.g. `STRIPE_SECRET_KEY`), which should never be injected into a bundle
+var secretKeyExpression = regexp.MustCompile(`(?i)(SECRET|PASSWORD|PASSWD|PRIVATE_?KEY|CREDENTIAL)`)
+
+// Writes a result in a reporter format
+type Reporter = func(w io.Writer, result *Result) error
+
+// Reporter formats, by name
+var Reporters = map[string]Reporter{
+	"github": WriteGitHub,
+	"junit":  WriteJUnit,
+	"sarif":  WriteSARIF,
+}
+
+// Environment variables found by a command, and any problems with them (never values)
+type Result struct {
+	// Command that found the environment variables (e.g. "check")
+	Command string
+	// Every environment variable key, sorted
+	Keys []Key
+	// Problems, in file order
+	Findings []Finding
+	// How long the command has taken
+	Duration time.Duration
+}
+
+type Key struct {
+	Name string
+	// Not optional, and has no default value
+	Required bool
+}
+
+// Problem with an occurrence of an environment variable
+type Finding struct {
+	Rule    string
+	Level   string
+	Key     string
+	Message string
+	// Path of the file (including `Reactenv.Dir`)
+	Path string
+	// Line of the occurrence (starts at 1)
+	Line int
+	// Column of the occurrence within `Line`, in UTF-16 code units (starts at 1)
+	Column int
+	// Byte offset and length of the occurrence within the file
+	Offset int
+	Length int
+}
+
+// Returns the keys and findings of every occurrence found by `renv` (see `Reactenv.FindOccurrences`)
+func FromReactenv(renv *reactenv.Reactenv, command string, duration time.Duration) (*Result, error) {
+	result := &Result{Command: command, Keys: make([]Key, 0), Findings: make([]Finding, 0), Duration: duration}
+
+	for _, key := range renv.OccurrenceKeysSorted() {
+		result.Keys = append(result.Keys, Key{Name: key, Required: renv.OccurrenceKeysRequired[key]})
+	}
+
+	// Error of each invalid occurrence, by file and start
+	invalid := make(map[*reactenv.File]map[int]error)
+	for _, occurrenceError := range renv.OccurrenceErrors {
+		if invalid[occurrenceError.File] == nil {
+			invalid[occurrenceError.File] = make(map[int]error)
+		}
+		invalid[occurrenceError.File][occurrenceError.Occurrence.StartEnd[0]] = occurrenceError.Err
+	}
+
+	missing := make(map[string]bool)
+	for _, key := range renv.MissingKeys() {
+		missing[key] = true
+	}
+
+	for fileIndex, fileOccurrences := range renv.OccurrencesByFile {
+		file := renv.Files[fileIndex]
+		findings := make([]Finding, 0)
+
+		for _, occurrence := range fileOccurrences.Occurrences {
+			start, end := reactenv.OccurrenceSpan(occurrence)
+			finding := Finding{Key: occurrence.Key, Path: renv.FilePath(file), Offset: start, Length: end - start}
+
+			if err, ok := invalid[file][occurrence.StartEnd[0]]; ok {
+				finding.Rule, finding.Level, finding.Message = RuleInvalidValue, LevelError, fmt.Sprintf("Environment variable '%s' can not be injected: %v", occurrence.Key, err)
+			} else if missing[occurrence.Key] {
+				finding.Rule, finding.Level, finding.Message = RuleMissingValue, LevelError, fmt.Sprintf("Environment variable '%s' is not set", occurrence.Key)
+			} else if secretKeyExpression.MatchString(occurrence.Key) {
+				finding.Rule, finding.Level, finding.Message = RuleSecretKey, LevelWarning, fmt.Sprintf("Environment variable '%s' looks like a secret, and would be visible to anyone in the injected file", occurrence.Key)
+			} else {
+				continue
+			}
+
+			findings = append(findings, finding)
+		}
+
+		if len(findings) == 0 {
+			continue
+		}
+
+		// Only files with findings are read again (streamed, as they may be large), to find the line and column of each
+		reader, err := renv.OpenFile(file)
+
+		if err != nil {
+			return nil, &reactenv.FileError{Path: file.Path, Op: "read", Err: err}
+		}
+
+		err = setPositions(findings, reader)
+		reader.Close()
+
+		if err != nil {
+			return nil, &reactenv.FileError{Path: file.Path, Op: "read", Err: err}
+		}
+
+		result.Findings = append(result.Findings, findings...)
+	}
+
+	return result, nil
+}
+
+// Sets the line and column of each finding (sorted by offset), reading the file from `reader`
+func setPositions(findings []Finding, reader io.Reader) error {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Offset < findings[j].Offset
+	})
+
+	buffered := bufio.NewReaderSize(reader, reactenv.REACTENV_STREAM_CHUNK_SIZE)
+
+	line := 1
+	// Column of the next rune, in UTF-16 code units
+	column := 1
+	offset := 0
+
+	for i := range findings {
+		for offset < findings[i].Offset {
+			r, size, err := buffered.ReadRune()
+
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return err
+			}
+
+			offset += size
+
+			switch {
+			case r == '\n':
+				line++
+				column = 1
+			case r >= 0x10000:
+				column += 2
+			default:
+				column++
+			}
+		}
+
+		findings[i].Line = line
+		findings[i].Column = column
+	}
+
+	return nil
+}
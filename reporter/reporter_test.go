@@ -0,0 +1,77 @@
+package reporter
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// Result with every kind of finding, and paths and messages that need escaping
+func testResult() *Result {
+	return &Result{
+		Command: "check",
+		Keys: []Key{
+			{Name: "API_URL", Required: true},
+			{Name: "FLAG", Required: false},
+			{Name: "PORT", Required: true},
+			{Name: "STRIPE_SECRET_KEY", Required: false},
+			{Name: "TITLE", Required: true},
+		},
+		Findings: []Finding{
+			{Rule: RuleMissingValue, Level: LevelError, Key: "API_URL", Message: "Environment variable 'API_URL' is not set", Path: "dist/index.js", Line: 1, Column: 12, Offset: 11, Length: 19},
+			{Rule: RuleInvalidValue, Level: LevelError, Key: "PORT", Message: "Environment variable 'PORT' can not be injected: 100% <invalid>\nnumber", Path: "dist/a,b:c.js", Line: 3, Column: 5, Offset: 40, Length: 23},
+			{Rule: RuleSecretKey, Level: LevelWarning, Key: "STRIPE_SECRET_KEY", Message: "Environment variable 'STRIPE_SECRET_KEY' looks like a secret", Path: "dist/a,b:c.js", Line: 4, Column: 1, Offset: 70, Length: 29},
+			{Rule: RuleMissingValue, Level: LevelError, Key: "API_URL", Message: "Environment variable 'API_URL' is not set", Path: "dist/chunks/x.js", Line: 10, Column: 2, Offset: 500, Length: 19},
+		},
+		Duration: 1500 * time.Millisecond,
+	}
+}
+
+// Writes `result` with `reporter`, and checks it matches `want`
+func testReporter(t *testing.T, reporter Reporter, result *Result, want string) {
+	t.Helper()
+
+	var got strings.Builder
+	if err := reporter(&got, result); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got.String(), want)
+	}
+}
+
+func TestSetPositions(t *testing.T) {
+	contents := "a\nbé😀__reactenv.A\n\n__reactenv.B__reactenv.C"
+
+	findings := []Finding{
+		{Key: "C", Offset: strings.LastIndex(contents, "__reactenv.C")},
+		{Key: "A", Offset: strings.Index(contents, "__reactenv.A")},
+		{Key: "B", Offset: strings.Index(contents, "__reactenv.B")},
+		// Past the end of the file (e.g. it changed since it was scanned)
+		{Key: "D", Offset: len(contents) + 10},
+	}
+
+	if err := setPositions(findings, iotest.OneByteReader(strings.NewReader(contents))); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		key    string
+		line   int
+		column int
+	}{
+		{"A", 2, 5},
+		{"B", 4, 1},
+		{"C", 4, 13},
+		{"D", 4, 25},
+	}
+
+	for i, position := range want {
+		finding := findings[i]
+		if finding.Key != position.key || finding.Line != position.line || finding.Column != position.column {
+			t.Errorf("finding %d is %s at %d:%d, want %s at %d:%d", i, finding.Key, finding.Line, finding.Column, position.key, position.line, position.column)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package reporter
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+
+	"github.com/hmerritt/reactenv/version"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// Description of each rule, in SARIF reports
+var sarifRules = []sarifRule{
+	{ID: RuleMissingValue, ShortDescription: sarifMessage{Text: "Required environment variable is not set"}, DefaultConfiguration: sarifConfiguration{Level: LevelError}},
+	{ID: RuleInvalidValue, ShortDescription: sarifMessage{Text: "Environment variable value can not be injected"}, DefaultConfiguration: sarifConfiguration{Level: LevelError}},
+	{ID: RuleSecretKey, ShortDescription: sarifMessage{Text: "Secret environment variable injected into a public file"}, DefaultConfiguration: sarifConfiguration{Level: LevelWarning}},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	ByteOffset  int `json:"byteOffset"`
+	ByteLength  int `json:"byteLength"`
+}
+
+// Writes a SARIF 2.1.0 log with a result for each finding, for code scanning
+// dashboards (e.g. GitHub code scanning).
+func WriteSARIF(w io.Writer, result *Result) error {
+	results := make([]sarifResult, 0, len(result.Findings))
+
+	for _, finding := range result.Findings {
+		results = append(results, sarifResult{
+			RuleID:  finding.Rule,
+			Level:   finding.Level,
+			Message: sarifMessage{Text: finding.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(finding.Path)},
+					Region: sarifRegion{
+						StartLine:   finding.Line,
+						StartColumn: finding.Column,
+						ByteOffset:  finding.Offset,
+						ByteLength:  finding.Length,
+					},
+				},
+			}},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "reactenv",
+				Version:        version.GetVersion().VersionNumber(),
+				InformationURI: "https://github.com/hmerritt/reactenv",
+				Rules:          sarifRules,
+			}},
+			ColumnKind: "utf16CodeUnits",
+			Results:    results,
+		}},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
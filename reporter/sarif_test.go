@@ -0,0 +1,149 @@
+package reporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hmerritt/reactenv/version"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	want := strings.ReplaceAll(`{
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "reactenv",
+          "version": "VERSION",
+          "informationUri": "https://github.com/hmerritt/reactenv",
+          "rules": [
+            {
+              "id": "missing-value",
+              "shortDescription": {
+                "text": "Required environment variable is not set"
+              },
+              "defaultConfiguration": {
+                "level": "error"
+              }
+            },
+            {
+              "id": "invalid-value",
+              "shortDescription": {
+                "text": "Environment variable value can not be injected"
+              },
+              "defaultConfiguration": {
+                "level": "error"
+              }
+            },
+            {
+              "id": "secret-key",
+              "shortDescription": {
+                "text": "Secret environment variable injected into a public file"
+              },
+              "defaultConfiguration": {
+                "level": "warning"
+              }
+            }
+          ]
+        }
+      },
+      "columnKind": "utf16CodeUnits",
+      "results": [
+        {
+          "ruleId": "missing-value",
+          "level": "error",
+          "message": {
+            "text": "Environment variable 'API_URL' is not set"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "dist/index.js"
+                },
+                "region": {
+                  "startLine": 1,
+                  "startColumn": 12,
+                  "byteOffset": 11,
+                  "byteLength": 19
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "invalid-value",
+          "level": "error",
+          "message": {
+            "text": "Environment variable 'PORT' can not be injected: 100% \u003cinvalid\u003e\nnumber"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "dist/a,b:c.js"
+                },
+                "region": {
+                  "startLine": 3,
+                  "startColumn": 5,
+                  "byteOffset": 40,
+                  "byteLength": 23
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "secret-key",
+          "level": "warning",
+          "message": {
+            "text": "Environment variable 'STRIPE_SECRET_KEY' looks like a secret"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "dist/a,b:c.js"
+                },
+                "region": {
+                  "startLine": 4,
+                  "startColumn": 1,
+                  "byteOffset": 70,
+                  "byteLength": 29
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "missing-value",
+          "level": "error",
+          "message": {
+            "text": "Environment variable 'API_URL' is not set"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "dist/chunks/x.js"
+                },
+                "region": {
+                  "startLine": 10,
+                  "startColumn": 2,
+                  "byteOffset": 500,
+                  "byteLength": 19
+                }
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`, "VERSION", version.GetVersion().VersionNumber())
+
+	testReporter(t, WriteSARIF, testResult(), want)
+}
@@ -1,19 +1,25 @@
 package ui
 
 import (
+	"github.com/fatih/color"
 	"github.com/schollz/progressbar/v3"
 )
 
 // Returns a progress bar with default options
 func GetProgressBar(length int, description string) *progressbar.ProgressBar {
+	saucer := "█"
+	if ASCII {
+		saucer = "="
+	}
+
 	return progressbar.NewOptions(length,
-		progressbar.OptionEnableColorCodes(true),
+		progressbar.OptionEnableColorCodes(!color.NoColor),
 		progressbar.OptionSetWidth(40),
 		progressbar.OptionShowCount(),
 		progressbar.OptionSetPredictTime(false),
 		progressbar.OptionSetDescription(description),
 		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "█", // "=", "█"
+			Saucer:        saucer,
 			SaucerHead:    "",
 			SaucerPadding: " ",
 			BarStart:      "|",
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"os"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -10,23 +11,35 @@ type Spin struct {
 	Spinner *spinner.Spinner
 }
 
-var Spinner = &Spin{
-	spinner.New(spinner.CharSets[14], 80*time.Millisecond),
-}
+// Shared spinner, its frames are updated by `SetASCII` (as `--ascii` is set after init)
+var Spinner = GetSpinner()
 
 func GetSpinner() *Spin {
 	return &Spin{
-		spinner.New(spinner.CharSets[14], 80*time.Millisecond),
+		spinner.New(spinnerCharSet(), 80*time.Millisecond),
+	}
+}
+
+// Returns the spinner frames to use (see `ASCII`)
+func spinnerCharSet() []string {
+	if ASCII {
+		return spinner.CharSets[9]
 	}
+	return spinner.CharSets[14]
 }
 
+// Spinners are only shown in a terminal, as each frame would be a new line in logs
 func (s *Spin) Start(prefix string, suffix string) {
 	s.UpdateText(prefix, suffix)
-	s.Spinner.Start()
+	if IsTerminal(os.Stdout) {
+		s.Spinner.Start()
+	}
 }
 
 func (s *Spin) StartEmpty() {
-	s.Spinner.Start()
+	if IsTerminal(os.Stdout) {
+		s.Spinner.Start()
+	}
 }
 
 func (s *Spin) Stop() {
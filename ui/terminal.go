@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// Output only ASCII characters (no emoji, or box drawing characters).
+//
+// Defaults to true for dumb terminals, and legacy Windows consoles.
+var ASCII = defaultASCII()
+
+// Checklist glyphs
+type Glyphs struct {
+	// Value has been set
+	Tick string
+	// Value is missing
+	Cross string
+	// Value is not set, but is not required
+	Dash string
+}
+
+var emojiGlyphs = Glyphs{Tick: "✅", Cross: "❌", Dash: "➖"}
+var asciiGlyphs = Glyphs{Tick: "[x]", Cross: "[ ]", Dash: "[-]"}
+
+func init() {
+	color.NoColor = !ColorEnabled(os.Stdout)
+}
+
+// Returns the checklist glyphs to use (see `ASCII`)
+func GetGlyphs() Glyphs {
+	if ASCII {
+		return asciiGlyphs
+	}
+	return emojiGlyphs
+}
+
+// Reports whether `file` is a terminal
+func IsTerminal(file *os.File) bool {
+	return isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd())
+}
+
+// Reports whether output to `file` should be colored.
+//
+// `FORCE_COLOR` always enables colors, and `NO_COLOR` always disables them
+// (https://force-color.org, https://no-color.org). Otherwise, colors are only
+// used when `file` is a terminal (that is not dumb).
+func ColorEnabled(file *os.File) bool {
+	if forceColor := os.Getenv("FORCE_COLOR"); forceColor != "" && forceColor != "0" && forceColor != "false" {
+		return true
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return IsTerminal(file)
+}
+
+// Enables or disables colors, for every Ui
+func SetNoColor(noColor bool) {
+	color.NoColor = noColor
+}
+
+// Enables or disables ASCII only output (see `ASCII`), including the spinner
+func SetASCII(ascii bool) {
+	ASCII = ascii
+	Spinner.Spinner.UpdateCharSet(spinnerCharSet())
+}
+
+// Reports whether the terminal is unlikely to display emoji (a dumb terminal, or
+// a legacy Windows console, rather than Windows Terminal or a terminal emulator)
+func defaultASCII() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return true
+	}
+	return runtime.GOOS == "windows" && os.Getenv("WT_SESSION") == "" && os.Getenv("TERM_PROGRAM") == "" && os.Getenv("TERM") == ""
+}
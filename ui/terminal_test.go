@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"os"
+	"testing"
+)
+
+// Returns a file that is a terminal (the master of a new pseudo-terminal), skipping if there is none
+func openTerminal(t *testing.T) *os.File {
+	t.Helper()
+
+	file, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+
+	if err != nil || !IsTerminal(file) {
+		t.Skip("no pseudo-terminal available", err)
+	}
+
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+// Returns a file that is not a terminal (a pipe)
+func openPipe(t *testing.T) *os.File {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		reader.Close()
+		writer.Close()
+	})
+	return writer
+}
+
+func TestColorEnabled(t *testing.T) {
+	tests := []struct {
+		name       string
+		noColor    string
+		forceColor string
+		term       string
+		terminal   bool
+		want       bool
+	}{
+		{"terminal", "", "", "xterm", true, true},
+		{"pipe", "", "", "xterm", false, false},
+		{"NO_COLOR in a terminal", "1", "", "xterm", true, false},
+		{"NO_COLOR in a pipe", "1", "", "xterm", false, false},
+		{"FORCE_COLOR in a terminal", "", "1", "xterm", true, true},
+		{"FORCE_COLOR in a pipe", "", "1", "xterm", false, true},
+		{"FORCE_COLOR=0 in a terminal", "", "0", "xterm", true, true},
+		{"FORCE_COLOR=false in a pipe", "", "false", "xterm", false, false},
+		{"FORCE_COLOR over NO_COLOR", "1", "1", "xterm", false, true},
+		{"dumb terminal", "", "", "dumb", true, false},
+		{"FORCE_COLOR in a dumb terminal", "", "3", "dumb", true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", test.noColor)
+			t.Setenv("FORCE_COLOR", test.forceColor)
+			t.Setenv("TERM", test.term)
+
+			var file *os.File
+			if test.terminal {
+				file = openTerminal(t)
+			} else {
+				file = openPipe(t)
+			}
+
+			if got := ColorEnabled(file); got != test.want {
+				t.Errorf("ColorEnabled() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetASCII(t *testing.T) {
+	defer SetASCII(ASCII)
+
+	SetASCII(true)
+	if glyphs := GetGlyphs(); glyphs != asciiGlyphs {
+		t.Errorf("GetGlyphs() = %+v, want %+v", glyphs, asciiGlyphs)
+	}
+	if frames := spinnerCharSet(); frames[0] != "|" {
+		t.Errorf("spinner frames are %q, want ASCII frames", frames)
+	}
+
+	SetASCII(false)
+	if glyphs := GetGlyphs(); glyphs != emojiGlyphs {
+		t.Errorf("GetGlyphs() = %+v, want %+v", glyphs, emojiGlyphs)
+	}
+}
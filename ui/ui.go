@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-colorable"
 	"github.com/mitchellh/cli"
 )
 
@@ -14,16 +15,19 @@ type Ui struct {
 	SuccessColor cli.UiColor
 }
 
+// Returns a Ui writing to stdout and stderr. Colors are only output when
+// enabled (see `ColorEnabled`), and are converted for legacy Windows consoles.
 func GetUi() *Ui {
 	return &Ui{
 		&cli.ColoredUi{
-			InfoColor:  cli.UiColorCyan,
-			ErrorColor: cli.UiColorRed,
-			WarnColor:  cli.UiColorYellow,
+			OutputColor: cli.UiColorNone,
+			InfoColor:   cli.UiColorCyan,
+			ErrorColor:  cli.UiColorRed,
+			WarnColor:   cli.UiColorYellow,
 			Ui: &cli.BasicUi{
 				Reader:      bufio.NewReader(os.Stdin),
-				Writer:      os.Stdout,
-				ErrorWriter: os.Stderr,
+				Writer:      colorable.NewColorableStdout(),
+				ErrorWriter: colorable.NewColorableStderr(),
 			},
 		},
 		cli.UiColorGreen,
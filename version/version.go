@@ -3,6 +3,8 @@ package version
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"os"
 )
 
 // VersionInfo
@@ -90,6 +92,11 @@ func (c *VersionInfo) FullVersionNumber(rev bool) string {
 }
 
 func PrintTitle() {
+	FprintTitle(os.Stdout)
+}
+
+// Prints the title to `w` (e.g. stderr, when stdout is used for machine-readable output)
+func FprintTitle(w io.Writer) {
 	// Get version info
 	versionStruct := GetVersion()
 
@@ -103,7 +110,7 @@ func PrintTitle() {
 	// Get full version string
 	versionString := versionStruct.FullVersionNumber(isDev)
 
-	fmt.Println(versionString)
-	fmt.Println("(c) MerrittCorp. All rights reserved.")
-	fmt.Println()
+	fmt.Fprintln(w, versionString)
+	fmt.Fprintln(w, "(c) MerrittCorp. All rights reserved.")
+	fmt.Fprintln(w)
 }